@@ -0,0 +1,88 @@
+package agents
+
+import (
+	"drone_simulation/store"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Checkpoint holds the state of a running simulation, from which it can be resumed
+type Checkpoint struct {
+	// Time is the simulated time when the checkpoint was taken
+	Time time.Time `json:"time"`
+	// Seq is the sequence number of the last event published before the checkpoint
+	Seq    int               `json:"seq"`
+	Drones []DroneCheckpoint `json:"drones"`
+}
+
+// DroneCheckpoint holds the progress of a drone that has not landed yet
+type DroneCheckpoint struct {
+	ID int `json:"id"`
+	// RouteIndex is the position in the route of the last waypoint reached, -1 before lift-off
+	RouteIndex int            `json:"route_index"`
+	Location   store.Location `json:"location"`
+	StartTime  time.Time      `json:"start_time"`
+	State      DroneState     `json:"state"`
+}
+
+// Checkpoint returns the state of every drone that has not landed yet, consistent with the events published so far
+func (d *dispatcher) Checkpoint() Checkpoint {
+	d.eventsMu.Lock()
+	defer d.eventsMu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	checkpoint := Checkpoint{Time: d.clock.Now(), Seq: d.seq, Drones: []DroneCheckpoint{}}
+	for id, f := range d.flights {
+		if f.landed {
+			continue
+		}
+
+		checkpoint.Drones = append(checkpoint.Drones, DroneCheckpoint{
+			ID:         id,
+			RouteIndex: f.index,
+			Location:   f.location,
+			StartTime:  f.startTime,
+			State:      f.state,
+		})
+	}
+	sort.Slice(checkpoint.Drones, func(i, j int) bool { return checkpoint.Drones[i].ID < checkpoint.Drones[j].ID })
+	return checkpoint
+}
+
+// SaveCheckpoint writes a checkpoint to a file, replacing the previous one only once it is fully written
+func SaveCheckpoint(path string, checkpoint Checkpoint) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(checkpoint); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+
+	file, err := os.Open(path)
+	if err != nil {
+		return checkpoint, err
+	}
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&checkpoint)
+	return checkpoint, err
+}
@@ -0,0 +1,92 @@
+package agents
+
+import (
+	"bytes"
+	"drone_simulation/store"
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint_Resume(t *testing.T) {
+	assert := assert.New(t)
+	NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	ids := []int{1, 2}
+	stationRepo := &store.MockStationRepository{
+		GetStationsFunc: func() ([]store.Station, error) {
+			return []store.Station{{Name: "Test Station", Latitude: 51.5, Longitude: -0.1}}, nil
+		},
+	}
+	newDrone := func(id int) Drone {
+		return NewDrone(id, DroneConfig{StationRepo: stationRepo, RandomSource: rand.NewPCG(42, uint64(id))})
+	}
+	routeRepo := testRouteRepo(testRoute(0, start, 25, time.Second))
+
+	// Given an uninterrupted run
+	var uninterrupted bytes.Buffer
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{
+		Clock: NewClock(ClockConfig{Synchronized: true, Start: start, Speed: 1000}),
+		Sinks: []EventSink{NewJournal(&uninterrupted)},
+	})
+	for _, id := range ids {
+		assert.NoError(dispatcher.AddDrone(newDrone(id), routeRepo, time.Time{}))
+	}
+	dispatcher.Wait()
+
+	// Given a run that is checkpointed half way through and then abandoned
+	var interrupted bytes.Buffer
+	dispatcher = NewDispatcherWithConfig(DispatcherConfig{
+		Clock: NewClock(ClockConfig{Synchronized: true, Start: start, Speed: 1000}),
+		Sinks: []EventSink{NewJournal(&interrupted)},
+	})
+	dispatcher.PauseAt(start.Add(12*time.Second + 500*time.Millisecond))
+	for _, id := range ids {
+		assert.NoError(dispatcher.AddDrone(newDrone(id), routeRepo, time.Time{}))
+	}
+	assert.Eventually(func() bool {
+		drones := dispatcher.ListDrones()
+		return drones[0].Location.Time.Equal(start.Add(12*time.Second)) && drones[1].Location.Time.Equal(start.Add(12*time.Second))
+	}, time.Second, time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	assert.NoError(SaveCheckpoint(path, dispatcher.Checkpoint()))
+	checkpoint, err := LoadCheckpoint(path)
+	assert.NoError(err)
+	assert.Len(checkpoint.Drones, 2)
+	assert.Equal(12, checkpoint.Drones[0].RouteIndex)
+	assert.InDelta(1-12*0.0111/BatteryRangeInKm, checkpoint.Drones[0].State.Battery, 0.0001, "twelve legs of 11 m flown")
+
+	// When the run is resumed from the checkpoint
+	var resumed bytes.Buffer
+	dispatcher = NewDispatcherWithConfig(DispatcherConfig{
+		Clock:   NewClock(ClockConfig{Synchronized: true, Start: checkpoint.Time, Speed: 1000}),
+		Sinks:   []EventSink{NewJournal(&resumed)},
+		LastSeq: checkpoint.Seq,
+	})
+	for _, droneCheckpoint := range checkpoint.Drones {
+		assert.NoError(dispatcher.ResumeDrone(newDrone(droneCheckpoint.ID), routeRepo, droneCheckpoint))
+	}
+	dispatcher.Wait()
+
+	// Then the events up to the checkpoint followed by the resumed events should match the uninterrupted run
+	expected, err := ReadJournal(&uninterrupted)
+	assert.NoError(err)
+	before, err := ReadJournal(&interrupted)
+	assert.NoError(err)
+	after, err := ReadJournal(&resumed)
+	assert.NoError(err)
+
+	var actual []Event
+	for _, event := range before {
+		if event.Seq <= checkpoint.Seq {
+			actual = append(actual, event)
+		}
+	}
+	actual = append(actual, after...)
+	assert.Empty(DiffJournals(expected, actual))
+	assert.Equal(len(expected), len(actual))
+}
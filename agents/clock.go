@@ -0,0 +1,249 @@
+package agents
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock defines how the dispatcher paces the waypoints it sends to drones
+type Clock interface {
+	Now() time.Time
+	Sleep(from, to time.Time, cancel <-chan struct{}) bool
+	Pause()
+	PauseAt(t time.Time)
+	Resume()
+	Step(d time.Duration)
+	StepWaypoint()
+	Paused() bool
+	Speed() float64
+	SetSpeed(speed float64)
+}
+
+// ClockConfig holds configuration for creating a simulation clock
+type ClockConfig struct {
+	// Synchronized releases every waypoint at its own timestamp on the shared clock,
+	// instead of letting each drone start flying immediately
+	Synchronized bool
+	// Start is the simulated time at which a synchronized clock starts
+	Start time.Time
+	// Speed is the number of simulated seconds per real second, 1 if not set
+	Speed float64
+}
+
+// SimulationClock is a clock shared by all drones of a simulation
+type SimulationClock struct {
+	mu           sync.Mutex
+	synchronized bool
+	speed        float64
+	simAnchor    time.Time
+	realAnchor   time.Time
+	paused       bool
+	pauseAt      *time.Time
+	waiters      map[int]time.Time
+	nextWaiter   int
+	// changed is closed and replaced whenever the clock is paused, resumed, stepped or changes speed
+	changed chan struct{}
+}
+
+// NewClock returns a new simulation clock, started at config.Start
+func NewClock(config ClockConfig) *SimulationClock {
+	speed := config.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	return &SimulationClock{
+		synchronized: config.Synchronized,
+		speed:        speed,
+		simAnchor:    config.Start,
+		realAnchor:   time.Now(),
+		waiters:      map[int]time.Time{},
+		changed:      make(chan struct{}),
+	}
+}
+
+// Now returns the current simulated time
+func (c *SimulationClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.nowLocked()
+}
+
+// Sleep blocks for the simulated time it takes to travel between two waypoints. A synchronized
+// clock blocks until the simulated time reaches the timestamp of the next waypoint. It returns false if cancel is
+// closed first, even while the clock is paused.
+func (c *SimulationClock) Sleep(from, to time.Time, cancel <-chan struct{}) bool {
+	if c.synchronized {
+		return c.waitUntil(to, cancel)
+	}
+	return c.waitUntil(c.Now().Add(to.Sub(from)), cancel)
+}
+
+// Synchronized reports whether waypoints are released at their own timestamps
+func (c *SimulationClock) Synchronized() bool {
+	return c.synchronized
+}
+
+// Pause freezes the simulated time, holding every drone at its current waypoint
+func (c *SimulationClock) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pauseLocked(c.nowLocked())
+}
+
+// PauseAt freezes the simulated time once it reaches t
+func (c *SimulationClock) PauseAt(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pauseAt = &t
+	c.nowLocked()
+	c.notifyLocked()
+}
+
+// Resume lets the simulated time run again from where it was paused
+func (c *SimulationClock) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	c.realAnchor = time.Now()
+	c.notifyLocked()
+}
+
+// Step pauses the clock and advances the simulated time by d, releasing the waypoints due in between
+func (c *SimulationClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pauseLocked(c.nowLocked().Add(d))
+}
+
+// StepWaypoint pauses the clock and advances the simulated time to the next waypoint any drone is waiting for
+func (c *SimulationClock) StepWaypoint() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.nowLocked()
+	var next *time.Time
+	for _, deadline := range c.waiters {
+		if next == nil || deadline.Before(*next) {
+			next = &deadline
+		}
+	}
+	if next == nil || next.Before(now) {
+		next = &now
+	}
+	c.pauseLocked(*next)
+}
+
+// Paused reports whether the simulated time is frozen
+func (c *SimulationClock) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nowLocked()
+	return c.paused
+}
+
+// Speed returns the number of simulated seconds per real second
+func (c *SimulationClock) Speed() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.speed
+}
+
+// SetSpeed changes the number of simulated seconds per real second from now on, ignoring a speed that is not positive
+func (c *SimulationClock) SetSpeed(speed float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if speed <= 0 {
+		return
+	}
+	if !c.paused {
+		c.simAnchor = c.nowLocked()
+		c.realAnchor = time.Now()
+	}
+	c.speed = speed
+	c.notifyLocked()
+}
+
+func (c *SimulationClock) nowLocked() time.Time {
+	if c.paused {
+		return c.simAnchor
+	}
+
+	elapsed := time.Since(c.realAnchor)
+	now := c.simAnchor.Add(time.Duration(float64(elapsed) * c.speed))
+	if c.pauseAt != nil && !now.Before(*c.pauseAt) {
+		c.pauseLocked(*c.pauseAt)
+		return c.simAnchor
+	}
+	return now
+}
+
+func (c *SimulationClock) pauseLocked(at time.Time) {
+	c.paused = true
+	c.pauseAt = nil
+	c.simAnchor = at
+	c.notifyLocked()
+}
+
+func (c *SimulationClock) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *SimulationClock) waitUntil(target time.Time, cancel <-chan struct{}) bool {
+	c.mu.Lock()
+	id := c.nextWaiter
+	c.nextWaiter++
+	c.waiters[id] = target
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.waiters, id)
+		c.mu.Unlock()
+	}()
+
+	for {
+		c.mu.Lock()
+		now := c.nowLocked()
+		paused, pauseAt, changed, speed := c.paused, c.pauseAt, c.changed, c.speed
+		c.mu.Unlock()
+
+		if !now.Before(target) {
+			return true
+		}
+		if paused {
+			select {
+			case <-changed:
+			case <-cancel:
+				return false
+			}
+			continue
+		}
+
+		wakeAt := target
+		if pauseAt != nil && pauseAt.Before(wakeAt) {
+			wakeAt = *pauseAt
+		}
+		timer := time.NewTimer(time.Duration(float64(wakeAt.Sub(now)) / speed))
+		select {
+		case <-timer.C:
+		case <-changed:
+			timer.Stop()
+		case <-cancel:
+			timer.Stop()
+			return false
+		}
+	}
+}
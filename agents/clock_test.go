@@ -0,0 +1,225 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sleepInBackground calls clock.Sleep(from, to, nil) and returns a channel closed once it returns, after the sleeper is
+// waiting on the clock
+func sleepInBackground(t *testing.T, clock *SimulationClock, from, to time.Time) <-chan struct{} {
+	released := make(chan struct{})
+	go func() {
+		clock.Sleep(from, to, nil)
+		close(released)
+	}()
+	assert.Eventually(t, func() bool {
+		select {
+		case <-released:
+			return true
+		default:
+		}
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.waiters) == 1
+	}, 5*time.Second, time.Millisecond)
+	return released
+}
+
+func isReleased(released <-chan struct{}) func() bool {
+	return func() bool {
+		select {
+		case <-released:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+func TestClock_Now(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a clock running a hundred times faster than real time
+	clock := NewClock(ClockConfig{Synchronized: true, Start: start, Speed: 100})
+
+	// Then its simulated time should advance a second at least every ten real milliseconds
+	assert.Eventually(t, func() bool { return clock.Now().Sub(start) >= time.Second }, 5*time.Second, time.Millisecond)
+}
+
+func TestClock_Sleep(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		synchronized bool
+		from         time.Time
+		to           time.Time
+		// held is how far the clock may be stepped without releasing the sleeper
+		held time.Duration
+		// release is how far the clock must be stepped to release it
+		release time.Duration
+	}{
+		{
+			name:    "Sleep() should block for the travel time when not synchronized",
+			from:    start.Add(time.Hour),
+			to:      start.Add(time.Hour + 2*time.Second),
+			held:    time.Second,
+			release: 2 * time.Second,
+		},
+		{
+			name:         "Sleep() should block until the next waypoint's timestamp when synchronized",
+			synchronized: true,
+			from:         start,
+			to:           start.Add(3 * time.Second),
+			held:         2 * time.Second,
+			release:      3 * time.Second,
+		},
+		{
+			name:         "Sleep() should not block for a waypoint in the past when synchronized",
+			synchronized: true,
+			from:         start.Add(-time.Hour),
+			to:           start.Add(-time.Minute),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given a paused clock and a drone sleeping on it
+			clock := NewClock(ClockConfig{Synchronized: testCase.synchronized, Start: start})
+			clock.Pause()
+			released := sleepInBackground(t, clock, testCase.from, testCase.to)
+
+			// When the clock is stepped short of the waypoint
+			if testCase.held > 0 {
+				clock.Step(testCase.held)
+				// Then the drone should still be sleeping
+				assert.Never(t, isReleased(released), 20*time.Millisecond, time.Millisecond)
+			}
+
+			// When the clock is stepped to the waypoint
+			clock.Step(testCase.release - testCase.held)
+			// Then the drone should wake up
+			assert.Eventually(t, isReleased(released), 5*time.Second, time.Millisecond)
+		})
+	}
+}
+
+func TestClock_SleepCancelled(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		paused bool
+	}{
+		{name: "Sleep() should return false once cancelled while the clock runs"},
+		{name: "Sleep() should return false once cancelled while the clock is paused", paused: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given a drone sleeping an hour on a clock
+			clock := NewClock(ClockConfig{Start: start})
+			if testCase.paused {
+				clock.Pause()
+			}
+			cancel, slept := make(chan struct{}), make(chan bool)
+			go func() { slept <- clock.Sleep(start, start.Add(time.Hour), cancel) }()
+
+			// When its sleep is cancelled
+			close(cancel)
+
+			// Then it should wake up at once
+			select {
+			case ok := <-slept:
+				assert.False(t, ok)
+			case <-time.After(5 * time.Second):
+				t.Fatal("cancelled sleep did not return")
+			}
+		})
+	}
+}
+
+func TestClock_PauseResume(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a paused clock
+	clock := NewClock(ClockConfig{Synchronized: true, Start: start})
+	clock.Pause()
+	paused := clock.Now()
+	assert.True(clock.Paused())
+
+	// Then its simulated time should not advance
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(paused, clock.Now())
+
+	// When it is resumed
+	clock.Resume()
+	// Then its simulated time should advance again from where it was paused
+	assert.False(clock.Paused())
+	assert.Eventually(func() bool { return clock.Now().After(paused) }, 5*time.Second, time.Millisecond)
+	assert.Less(clock.Now().Sub(paused), time.Minute)
+}
+
+func TestClock_PauseAt(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a clock set to pause one simulated second after it starts
+	clock := NewClock(ClockConfig{Synchronized: true, Start: start, Speed: 100})
+	clock.PauseAt(start.Add(time.Second))
+
+	// Then it should freeze exactly at that time
+	assert.Eventually(t, clock.Paused, 5*time.Second, time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), clock.Now())
+}
+
+func TestClock_Step(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a paused clock and a drone waiting for a waypoint ten simulated seconds ahead
+	clock := NewClock(ClockConfig{Synchronized: true, Start: start})
+	clock.Pause()
+	paused := clock.Now()
+	released := sleepInBackground(t, clock, start, start.Add(10*time.Second))
+
+	// When the clock is stepped by one simulated second
+	clock.Step(time.Second)
+	// Then the waypoint should not be released yet
+	assert.Never(isReleased(released), 20*time.Millisecond, time.Millisecond)
+	assert.Equal(paused.Add(time.Second), clock.Now())
+
+	// When the clock is stepped to the next waypoint
+	clock.StepWaypoint()
+	// Then the waypoint should be released with the clock still paused at its timestamp
+	assert.Eventually(isReleased(released), 5*time.Second, time.Millisecond)
+	assert.True(clock.Paused())
+	assert.Equal(start.Add(10*time.Second), clock.Now())
+}
+
+func TestClock_SetSpeed(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a paused real-time clock and a drone waiting for a waypoint a simulated minute ahead
+	clock := NewClock(ClockConfig{Synchronized: true, Start: start})
+	clock.Pause()
+	paused := clock.Now()
+	released := sleepInBackground(t, clock, start, start.Add(time.Minute))
+
+	// When the clock is sped up a thousand times, ignoring a speed that is not positive
+	clock.SetSpeed(1000)
+	clock.SetSpeed(0)
+	// Then its simulated time should not move while paused
+	assert.Equal(1000.0, clock.Speed())
+	assert.Equal(paused, clock.Now())
+
+	// When it is resumed
+	clock.Resume()
+	// Then the waypoint should be released after sixty real milliseconds rather than a minute
+	assert.Eventually(isReleased(released), 5*time.Second, time.Millisecond)
+	assert.False(clock.Now().Before(start.Add(time.Minute)))
+}
@@ -2,59 +2,471 @@ package agents
 
 import (
 	"drone_simulation/store"
+	"errors"
+	"iter"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	// ErrDroneExists is returned when adding a drone whose ID is already flying
+	ErrDroneExists = errors.New("drone already registered")
+	// ErrUnknownDrone is returned when removing a drone that is not flying
+	ErrUnknownDrone = errors.New("drone not registered")
+)
+
 // Dispatcher defines the behaviours of a dispatcher
 type Dispatcher interface {
 	Fly(drone Drone, wg *sync.WaitGroup)
+	AddDrone(drone Drone, routeRepo store.RouteRepository, startTime time.Time) error
+	ResumeDrone(drone Drone, routeRepo store.RouteRepository, checkpoint DroneCheckpoint) error
+	RemoveDrone(id int) error
+	ListDrones() []DroneInfo
+	Wait()
+	Now() time.Time
+	Pause()
+	PauseAt(t time.Time)
+	Resume()
+	Step(d time.Duration)
+	StepWaypoint()
+	Paused() bool
+	Speed() float64
+	SetSpeed(speed float64)
+	Checkpoint() Checkpoint
+}
+
+// DroneInfo describes a drone registered with the dispatcher
+type DroneInfo struct {
+	ID        int
+	Status    string
+	Location  store.Location
+	StartTime time.Time
+}
+
+// flight holds the state of a single drone flown by the dispatcher
+type flight struct {
+	drone     Drone
+	routeRepo store.RouteRepository
+	startTime time.Time
+	status    string
+	location  store.Location
+	reported  int
+	stop      chan struct{}
+	// index is the position in the route of the last waypoint reached, -1 before lift-off
+	index   int
+	resumed bool
+	landed  bool
+	state   DroneState
+}
+
+// DispatcherConfig holds configuration for creating a dispatcher
+type DispatcherConfig struct {
+	ShutDownTime *time.Time
+	Clock        Clock
+	Sinks        []EventSink
+	// LastSeq is the sequence number of the last event before the dispatcher starts, when resuming from a checkpoint
+	LastSeq int
+	// Fusion fuses the reports of several drones at a station close together in time, if set. The recent reports
+	// are not saved in checkpoints, so a resumed run only fuses those made after it resumed.
+	Fusion *FusionConfig
 }
 
 type dispatcher struct {
 	shutDownTime *time.Time
+	clock        Clock
+	mu           sync.Mutex
+	flights      map[int]*flight
+	wg           sync.WaitGroup
+	eventsMu     sync.Mutex
+	seq          int
+	sinks        []EventSink
+	fuser        *fuser
 }
 
-// NewDispatcher returns a new dispatcher
+// NewDispatcher returns a new dispatcher whose drones start flying as soon as they are added
 func NewDispatcher(shutDownTime *time.Time) Dispatcher {
-	return &dispatcher{shutDownTime}
+	return NewDispatcherWithConfig(DispatcherConfig{ShutDownTime: shutDownTime})
 }
 
+// NewDispatcherWithConfig returns a new dispatcher paced by the configured clock
+func NewDispatcherWithConfig(config DispatcherConfig) Dispatcher {
+	clock := config.Clock
+	if clock == nil {
+		clock = NewClock(ClockConfig{})
+	}
+
+	d := &dispatcher{
+		shutDownTime: config.ShutDownTime,
+		clock:        clock,
+		flights:      map[int]*flight{},
+		sinks:        config.Sinks,
+		seq:          config.LastSeq,
+	}
+	if config.Fusion != nil {
+		d.fuser = newFuser(*config.Fusion)
+	}
+	return d
+}
+
+// Fly flies a drone along its default route, without registering it with the dispatcher
 func (d *dispatcher) Fly(drone Drone, wg *sync.WaitGroup) {
-	defer drone.ShutDown()
 	defer wg.Done()
 
+	d.fly(&flight{
+		drone:     drone,
+		routeRepo: store.DefaultRouteRepository{},
+		status:    statusOff,
+		stop:      make(chan struct{}),
+		index:     -1,
+	})
+}
+
+// AddDrone launches a drone along the route from routeRepo, skipping the waypoints before startTime
+func (d *dispatcher) AddDrone(drone Drone, routeRepo store.RouteRepository, startTime time.Time) error {
+	return d.launch(&flight{
+		drone:     drone,
+		routeRepo: routeRepo,
+		startTime: startTime,
+		status:    statusOff,
+		stop:      make(chan struct{}),
+		index:     -1,
+	})
+}
+
+// ResumeDrone restores a drone from a checkpoint and flies it on from the last waypoint it reached
+func (d *dispatcher) ResumeDrone(drone Drone, routeRepo store.RouteRepository, checkpoint DroneCheckpoint) error {
+	if checkpoint.RouteIndex < 0 {
+		return d.AddDrone(drone, routeRepo, checkpoint.StartTime)
+	}
+	if err := drone.Restore(checkpoint.State); err != nil {
+		return err
+	}
+
+	return d.launch(&flight{
+		drone:     drone,
+		routeRepo: routeRepo,
+		startTime: checkpoint.StartTime,
+		status:    checkpoint.State.Status,
+		location:  checkpoint.Location,
+		reported:  len(checkpoint.State.Reports),
+		stop:      make(chan struct{}),
+		index:     checkpoint.RouteIndex,
+		resumed:   true,
+		state:     checkpoint.State,
+	})
+}
+
+func (d *dispatcher) launch(f *flight) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := f.drone.ID()
+	if _, ok := d.flights[id]; ok {
+		return ErrDroneExists
+	}
+	d.flights[id] = f
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer d.unregister(id, f)
+		d.fly(f)
+	}()
+	return nil
+}
+
+// RemoveDrone lands a flying drone at the last waypoint it reached, without waiting for the next one
+func (d *dispatcher) RemoveDrone(id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, ok := d.flights[id]
+	if !ok {
+		return ErrUnknownDrone
+	}
+
+	delete(d.flights, id)
+	close(f.stop)
+	return nil
+}
+
+// ListDrones returns the drones currently flying, ordered by ID
+func (d *dispatcher) ListDrones() []DroneInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	drones := make([]DroneInfo, 0, len(d.flights))
+	for id, f := range d.flights {
+		drones = append(drones, DroneInfo{ID: id, Status: f.status, Location: f.location, StartTime: f.startTime})
+	}
+	sort.Slice(drones, func(i, j int) bool { return drones[i].ID < drones[j].ID })
+	return drones
+}
+
+// Wait blocks until every drone added to the dispatcher, including those added while waiting, has shut down
+func (d *dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Now returns the current simulated time of the dispatcher's clock
+func (d *dispatcher) Now() time.Time {
+	return d.clock.Now()
+}
+
+// Pause holds every drone at its current waypoint until the dispatcher is resumed or stepped
+func (d *dispatcher) Pause() {
+	d.clock.Pause()
+	logrus.WithField("Time", d.clock.Now().Format(time.TimeOnly)).Info("Paused")
+}
+
+// PauseAt pauses the dispatcher once the simulated time reaches t
+func (d *dispatcher) PauseAt(t time.Time) {
+	d.clock.PauseAt(t)
+}
+
+// Resume lets the drones fly again after a pause
+func (d *dispatcher) Resume() {
+	d.clock.Resume()
+	logrus.WithField("Time", d.clock.Now().Format(time.TimeOnly)).Info("Resumed")
+}
+
+// Step pauses the simulation and advances it by the given simulated duration
+func (d *dispatcher) Step(duration time.Duration) {
+	d.clock.Step(duration)
+}
+
+// StepWaypoint pauses the simulation and advances it to the next waypoint any drone is waiting for
+func (d *dispatcher) StepWaypoint() {
+	d.clock.StepWaypoint()
+}
+
+// Paused reports whether the dispatcher's clock is frozen
+func (d *dispatcher) Paused() bool {
+	return d.clock.Paused()
+}
+
+// Speed returns the number of simulated seconds per real second of the dispatcher's clock
+func (d *dispatcher) Speed() float64 {
+	return d.clock.Speed()
+}
+
+// SetSpeed changes the number of simulated seconds per real second of the dispatcher's clock
+func (d *dispatcher) SetSpeed(speed float64) {
+	d.clock.SetSpeed(speed)
+	logrus.WithField("Speed", d.clock.Speed()).Info("Speed changed")
+}
+
+func (d *dispatcher) unregister(id int, f *flight) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.flights[id] == f {
+		delete(d.flights, id)
+	}
+}
+
+func (d *dispatcher) fly(f *flight) {
+	drone := f.drone
 	id := drone.ID()
+	reason, failure := "end of route", ""
+	defer func() {
+		drone.ShutDown()
+		d.land(f, Event{Kind: EventShutDown, DroneID: id, Time: f.location.Time, Reason: reason, Error: failure})
+	}()
+
 	logger := logrus.WithField("Drone", id)
-	route, err := store.Route(id)
-	if err != nil {
-		logger.Error("Could not parse route, aborting")
-		return
+	launched := f.resumed
+	currentLocation := f.location
+	var events []Event
+	stopped := func() bool {
+		select {
+		case <-f.stop:
+			logger.Info("Removed from fleet")
+			reason = "removed"
+			return true
+		default:
+			return false
+		}
 	}
 
-	drone.Start()
-	currentLocation := route[0]
-	for _, nextLocation := range route {
+	// the route is streamed, so that only the waypoints being flown are held in memory, and the waypoints of a
+	// live route are flown as they arrive rather than paced by the clock
+	live := store.IsLive(f.routeRepo)
+	route := store.StreamRoute(f.routeRepo, id)
+	if live {
+		route = untilStopped(route, f.stop)
+	}
+	i := -1
+	for nextLocation, err := range route {
+		if err != nil {
+			logger.Errorf("Could not parse route, aborting: %s", err)
+			reason, failure = "invalid route", err.Error()
+			return
+		}
+
+		i++
+		if (f.resumed && i <= f.index) || (!f.resumed && nextLocation.Time.Before(f.startTime)) {
+			continue
+		}
+
 		if d.shutDownTime != nil && d.shutDownTime.Sub(nextLocation.Time) <= 0 {
+			reason = "shutdown time"
 			return
 		}
 
+		if stopped() {
+			return
+		}
+
+		if !launched {
+			drone.Start()
+			events = append(events, Event{Kind: EventLaunch, DroneID: id, Time: nextLocation.Time})
+			currentLocation = nextLocation
+			launched = true
+		}
+
+		if !live {
+			d.clock.Sleep(currentLocation.Time, nextLocation.Time, f.stop)
+		}
+		if stopped() {
+			return
+		}
 		location := drone.Move(currentLocation, nextLocation)
 		if location == currentLocation {
 			if !drone.IsOn() || !drone.HasMemory() {
+				restart := Event{Kind: EventRestart, DroneID: id, Time: currentLocation.Time, Reason: restartReason(drone)}
 				logger.Info("Trying to restart")
 				drone.Start()
+				f.reported = 0
+				events = append(events, restart)
 				location = drone.Move(currentLocation, nextLocation)
 			}
 
 			if location != nextLocation {
 				logger.Error("Restart failed, aborting")
+				reason = "restart failed"
 				return
 			}
 		}
 
 		currentLocation = location
+		events = append(events, Event{Kind: EventMove, DroneID: id, Time: location.Time, Location: &location, Battery: drone.Battery()})
+		events = append(events, d.newReports(f)...)
+		d.arrive(f, i, location, events)
+		events = nil
+	}
+
+	if stopped() {
+		return
+	}
+	if !launched {
+		logger.Error("No route after start time, aborting")
+		reason = "empty route"
+	}
+}
+
+// restartReason returns why a drone shut itself down instead of moving
+func restartReason(drone Drone) string {
+	if len(drone.Reports()) >= MaxMemory {
+		return RestartOutOfMemory
+	}
+	return RestartBatteryFlat
+}
+
+// untilStopped reads a live route in the background, ending it as soon as stop is closed rather than once its
+// next waypoint arrives
+func untilStopped(route iter.Seq2[store.Location, error], stop <-chan struct{}) iter.Seq2[store.Location, error] {
+	type waypoint struct {
+		location store.Location
+		err      error
+	}
+	return func(yield func(store.Location, error) bool) {
+		waypoints, done := make(chan waypoint), make(chan struct{})
+		defer close(done)
+		go func() {
+			defer close(waypoints)
+			for location, err := range route {
+				select {
+				case waypoints <- waypoint{location, err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case next, ok := <-waypoints:
+				if !ok || !yield(next.location, next.err) {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// newReports returns report events for the reports a drone has made since the last time it was asked
+func (d *dispatcher) newReports(f *flight) []Event {
+	var events []Event
+	reports := f.drone.Reports()
+	for _, report := range reports[f.reported:] {
+		events = append(events, Event{Kind: EventReport, DroneID: report.DroneID, Time: report.Time, Report: &report})
+	}
+	f.reported = len(reports)
+	return events
+}
+
+// arrive publishes the events that led a drone to a waypoint and records its progress, both at once so that
+// a checkpoint never sees one without the other
+func (d *dispatcher) arrive(f *flight, index int, location store.Location, events []Event) {
+	d.eventsMu.Lock()
+	defer d.eventsMu.Unlock()
+
+	for _, event := range events {
+		d.publishLocked(event)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f.index = index
+	f.location = location
+	f.state = f.drone.Snapshot()
+	f.status = f.state.Status
+}
+
+// land publishes the shutdown of a drone and marks its flight as over
+func (d *dispatcher) land(f *flight, event Event) {
+	d.eventsMu.Lock()
+	defer d.eventsMu.Unlock()
+
+	d.publishLocked(event)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f.status = statusOff
+	f.landed = true
+}
+
+// publishLocked numbers an event and hands it to every sink, in the order the events happened, followed by the
+// fusion of a report with those of other drones
+func (d *dispatcher) publishLocked(event Event) {
+	d.seq++
+	event.Seq = d.seq
+	for _, sink := range d.sinks {
+		sink.Handle(event)
+	}
+
+	if event.Kind == EventReport && event.Report != nil && d.fuser != nil {
+		if fused := d.fuser.add(*event.Report); fused != nil {
+			logFusion(*fused)
+			d.publishLocked(*fused)
+		}
 	}
 }
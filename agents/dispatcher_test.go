@@ -1,17 +1,311 @@
 package agents
 
 import (
+	"drone_simulation/store"
+	"iter"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestShutDown(t *testing.T) {
-	// Given a dispatcher and a drone
-	// When the dispatcher flies the drone
-	// Then before terminating the dispatcher should shut down the drone correctly
+	helper := NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := testRoute(1, start, 3, time.Hour)
+
+	testCases := []struct {
+		name      string
+		routeRepo store.RouteRepository
+		// remove removes the drone once it has lifted off, instead of letting it fly to the end of its route
+		remove         bool
+		expectedReason string
+	}{
+		{
+			name:           "Fly() should shut the drone down at the end of its route",
+			routeRepo:      testRouteRepo(testRoute(1, start, 3, time.Millisecond)),
+			expectedReason: "end of route",
+		},
+		{
+			name:           "RemoveDrone() should shut down a drone sleeping until its next waypoint on a paused clock",
+			routeRepo:      testRouteRepo(route),
+			remove:         true,
+			expectedReason: "removed",
+		},
+		{
+			name:           "RemoveDrone() should shut down a drone waiting for the next waypoint of a live route",
+			routeRepo:      &liveRouteRepo{first: route[0]},
+			remove:         true,
+			expectedReason: "removed",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Given a dispatcher and a drone
+			var shutDowns []Event
+			launched := make(chan struct{}, 1)
+			clock := NewClock(ClockConfig{})
+			dispatcher := NewDispatcherWithConfig(DispatcherConfig{Clock: clock, Sinks: []EventSink{EventSinkFunc(func(event Event) {
+				switch event.Kind {
+				case EventMove:
+					select {
+					case launched <- struct{}{}:
+					default:
+					}
+				case EventShutDown:
+					shutDowns = append(shutDowns, event)
+				}
+			})}})
+			drone := helper.CreateTestDrone(1, helper.CreateMockStationRepoEmpty())
+
+			// When the dispatcher flies the drone
+			assert.NoError(dispatcher.AddDrone(drone, testCase.routeRepo, time.Time{}))
+			if testCase.remove {
+				// and it is removed once it lifted off, with the clock paused before its next waypoint
+				<-launched
+				clock.Pause()
+				assert.NoError(dispatcher.RemoveDrone(1))
+			}
+
+			// Then before terminating the dispatcher should shut down the drone correctly
+			done := make(chan struct{})
+			go func() {
+				dispatcher.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("drone did not land")
+			}
+			assert.False(drone.IsOn())
+			assert.Len(shutDowns, 1)
+			assert.Equal(testCase.expectedReason, shutDowns[0].Reason)
+		})
+	}
 }
 
 func TestRestart(t *testing.T) {
-	// Given a dispatcher and a drone
+	assert := assert.New(t)
+	helper := NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := testRoute(1, start, MaxMemory+3, time.Millisecond)
+
+	// Given a dispatcher and a drone reporting a station at every waypoint
+	var moves, restarts []Event
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{Sinks: []EventSink{EventSinkFunc(func(event Event) {
+		switch event.Kind {
+		case EventMove:
+			moves = append(moves, event)
+		case EventRestart:
+			restarts = append(restarts, event)
+		}
+	})}})
+	drone := helper.CreateTestDrone(1, &store.MockStationRepository{
+		GetStationsFunc: func() ([]store.Station, error) {
+			return []store.Station{{Name: "Overhead", Latitude: 51.5005, Longitude: -0.1}}, nil
+		},
+	})
+
 	// When the dispatcher flies the drone and the drone runs out of memory
+	assert.NoError(dispatcher.AddDrone(drone, testRouteRepo(route), time.Time{}))
+	dispatcher.Wait()
+
 	// Then the dispatcher should try to restart the drone with wiped memory and continue on the given route
+	assert.Len(restarts, 1)
+	assert.Equal(route[MaxMemory-1].Time, restarts[0].Time)
+	assert.Equal(RestartOutOfMemory, restarts[0].Reason)
+	assert.Len(moves, len(route))
+	assert.Equal(route[len(route)-1], *moves[len(moves)-1].Location)
+	assert.Len(drone.Reports(), len(route)-MaxMemory)
+	assert.False(drone.IsOn())
+}
+
+func testRoute(id int, start time.Time, waypoints int, interval time.Duration) []store.Location {
+	route := make([]store.Location, waypoints)
+	for i := range route {
+		route[i] = store.Location{
+			DroneID:   id,
+			Latitude:  51.5 + float64(i)*0.0001,
+			Longitude: -0.1,
+			Time:      start.Add(time.Duration(i) * interval),
+		}
+	}
+	return route
+}
+
+// liveRouteRepo is a live route whose first waypoint arrives at once, and no other ever does
+type liveRouteRepo struct {
+	first store.Location
+}
+
+func (r *liveRouteRepo) GetRoute(id int) ([]store.Location, error) {
+	return []store.Location{r.first}, nil
+}
+
+func (r *liveRouteRepo) StreamRoute(id int) iter.Seq2[store.Location, error] {
+	return func(yield func(store.Location, error) bool) {
+		if yield(r.first, nil) {
+			select {}
+		}
+	}
+}
+
+func (r *liveRouteRepo) Live() bool {
+	return true
+}
+
+func testRouteRepo(route []store.Location) *store.MockRouteRepository {
+	return &store.MockRouteRepository{
+		GetRouteFunc: func(id int) ([]store.Location, error) {
+			return route, nil
+		},
+	}
+}
+
+func TestAddDrone_ListDrones_Wait(t *testing.T) {
+	assert := assert.New(t)
+	helper := NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a dispatcher with two drones on short routes, held on the ground by a paused clock
+	clock := NewClock(ClockConfig{})
+	clock.Pause()
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{Clock: clock})
+	assert.NoError(dispatcher.AddDrone(helper.CreateTestDrone(1, helper.CreateMockStationRepoEmpty()), testRouteRepo(testRoute(1, start, 3, 10*time.Millisecond)), time.Time{}))
+	assert.NoError(dispatcher.AddDrone(helper.CreateTestDrone(2, helper.CreateMockStationRepoEmpty()), testRouteRepo(testRoute(2, start, 3, 10*time.Millisecond)), time.Time{}))
+
+	// When a drone with the same ID is added
+	// Then it should be rejected
+	assert.ErrorIs(dispatcher.AddDrone(helper.CreateTestDrone(1, helper.CreateMockStationRepoEmpty()), testRouteRepo(nil), time.Time{}), ErrDroneExists)
+
+	// Then both drones should be listed in ID order
+	drones := dispatcher.ListDrones()
+	assert.Len(drones, 2)
+	assert.Equal(1, drones[0].ID)
+	assert.Equal(2, drones[1].ID)
+
+	// When the dispatcher waits for the fleet
+	dispatcher.Resume()
+	dispatcher.Wait()
+	// Then no drone should be flying any more
+	assert.Empty(dispatcher.ListDrones())
+}
+
+func TestAddDrone_StartTime(t *testing.T) {
+	assert := assert.New(t)
+	helper := NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := testRoute(1, start, 5, time.Millisecond)
+
+	// Given a drone added with a start time in the middle of its route
+	var moves []store.Location
+	var batteries []float64
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{Sinks: []EventSink{EventSinkFunc(func(event Event) {
+		if event.Kind == EventMove {
+			moves = append(moves, *event.Location)
+			batteries = append(batteries, event.Battery)
+		}
+	})}})
+	drone := helper.CreateTestDrone(1, helper.CreateMockStationRepoEmpty())
+	assert.NoError(dispatcher.AddDrone(drone, testRouteRepo(route), route[3].Time))
+	dispatcher.Wait()
+
+	// Then it should have skipped the earlier waypoints and still reached the end of the route
+	assert.False(drone.IsOn())
+	assert.Equal([]store.Location{route[3], route[4]}, moves)
+	// with the charge left after each move, lifting off costing none
+	assert.Len(batteries, 2)
+	assert.Equal(1.0, batteries[0])
+	assert.Less(batteries[1], 1.0)
+}
+
+func TestRemoveDrone(t *testing.T) {
+	assert := assert.New(t)
+	helper := NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a dispatcher with a drone on a long route
+	dispatcher := NewDispatcher(nil)
+	drone := helper.CreateTestDrone(1, helper.CreateMockStationRepoEmpty())
+	assert.NoError(dispatcher.AddDrone(drone, testRouteRepo(testRoute(1, start, 1000, 10*time.Millisecond)), time.Time{}))
+
+	// When the drone is removed
+	assert.NoError(dispatcher.RemoveDrone(1))
+	// Then it should no longer be listed and removing it again should fail
+	assert.Empty(dispatcher.ListDrones())
+	assert.ErrorIs(dispatcher.RemoveDrone(1), ErrUnknownDrone)
+
+	// Then the dispatcher should shut it down well before the end of its route
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("removed drone did not land")
+	}
+	assert.False(drone.IsOn())
+}
+
+func TestDispatcher_SynchronizedClock(t *testing.T) {
+	assert := assert.New(t)
+	helper := NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a paused synchronized dispatcher and a drone whose route begins five simulated seconds after the clock
+	clock := NewClock(ClockConfig{Synchronized: true, Start: start})
+	clock.Pause()
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{Clock: clock})
+	drone := helper.CreateTestDrone(1, helper.CreateMockStationRepoEmpty())
+	route := testRoute(1, start.Add(5*time.Second), 3, time.Second)
+	assert.NoError(dispatcher.AddDrone(drone, testRouteRepo(route), time.Time{}))
+
+	// When the clock is stepped short of its first waypoint
+	clock.Step(4 * time.Second)
+	// Then it should not lift off
+	assert.Never(func() bool { return dispatcher.ListDrones()[0].Location != store.Location{} }, 20*time.Millisecond, time.Millisecond)
+
+	// When the clock is stepped to its first waypoint
+	clock.Step(time.Second)
+	// Then it should lift off
+	assert.Eventually(func() bool { return dispatcher.ListDrones()[0].Location == route[0] }, 5*time.Second, time.Millisecond)
+
+	// Then it should land once the clock is stepped to its last waypoint
+	clock.Step(2 * time.Second)
+	dispatcher.Wait()
+	assert.False(clock.Now().Before(route[2].Time))
+}
+
+func TestDispatcher_PauseStepResume(t *testing.T) {
+	assert := assert.New(t)
+	helper := NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := testRoute(1, start, 3, time.Second)
+
+	// Given a paused synchronized dispatcher flying a drone
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{Clock: NewClock(ClockConfig{Synchronized: true, Start: start})})
+	dispatcher.Pause()
+	assert.NoError(dispatcher.AddDrone(helper.CreateTestDrone(1, helper.CreateMockStationRepoEmpty()), testRouteRepo(route), time.Time{}))
+
+	// When the dispatcher is stepped waypoint by waypoint
+	// Then the drone should reach each waypoint in turn
+	for _, waypoint := range route {
+		assert.Eventually(func() bool {
+			dispatcher.StepWaypoint()
+			drones := dispatcher.ListDrones()
+			return len(drones) == 0 || drones[0].Location == waypoint
+		}, time.Second, time.Millisecond)
+	}
+
+	// When the dispatcher is resumed
+	dispatcher.Resume()
+	// Then the drone should land
+	dispatcher.Wait()
+	assert.Empty(dispatcher.ListDrones())
 }
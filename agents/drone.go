@@ -3,7 +3,7 @@ package agents
 import (
 	"drone_simulation/store"
 	"fmt"
-	"math/rand"
+	"math/rand/v2"
 	"strings"
 	"time"
 
@@ -12,21 +12,32 @@ import (
 )
 
 const (
-	statusOn          string  = "on"
-	statusOff         string  = "off"
-	maxVisibilityInKm float64 = 0.35
-	maxMemory         int     = 10
-	earthRadiusInKm   int     = 6371
-	nanoSecsInAnHour  float64 = 2.77778e-13
+	statusOn         string  = "on"
+	statusOff        string  = "off"
+	earthRadiusInKm  int     = 6371
+	nanoSecsInAnHour float64 = 2.77778e-13
 )
 
-var trafficScores = []string{"HEAVY", "LIGHT", "MODERATE"}
+// MaxVisibilityInKm is the distance within which a drone sees a station and reports its traffic
+const MaxVisibilityInKm float64 = 0.35
+
+// MaxMemory is the number of reports a drone holds before it runs out of memory and has to restart
+const MaxMemory int = 10
+
+// BatteryRangeInKm is the distance a drone flies on a full battery
+const BatteryRangeInKm float64 = 20
+
+var trafficScores = []string{store.TrafficHeavy, store.TrafficLight, store.TrafficModerate}
 
 // Drone defines the behaviours of a drone
 type Drone interface {
 	ID() int
 	IsOn() bool
 	HasMemory() bool
+	Battery() float64
+	Reports() []store.TrafficReport
+	Snapshot() DroneState
+	Restore(state DroneState) error
 	Start()
 	Move(location, nextLocation store.Location) store.Location
 	calculateCurrentSpeed(previousLocation, location store.Location, timeTravelled time.Duration) (speedInKph float64)
@@ -37,15 +48,29 @@ type Drone interface {
 // DroneConfig holds configuration for creating a drone
 type DroneConfig struct {
 	StationRepo store.StationRepository
+	// RandomSource decides the reported traffic conditions, randomly seeded if not set
+	RandomSource *rand.PCG
+}
+
+// DroneState holds the state of a drone saved in a checkpoint
+type DroneState struct {
+	Status      string                `json:"status"`
+	Reports     []store.TrafficReport `json:"reports"`
+	Battery     float64               `json:"battery"`
+	RandomState []byte                `json:"random_state"`
 }
 
 // drone struct with injected dependencies
 type drone struct {
-	id             int
-	status         string
-	stations       []store.Station
-	trafficReports int
-	stationRepo    store.StationRepository
+	id       int
+	status   string
+	stations []store.Station
+	reports  []store.TrafficReport
+	// battery is the charge left, from 0 when flat to 1 when full
+	battery      float64
+	stationRepo  store.StationRepository
+	randomSource *rand.PCG
+	random       *rand.Rand
 }
 
 // NewDrone returns a new drone
@@ -55,7 +80,20 @@ func NewDrone(id int, config DroneConfig) Drone {
 		logrus.WithField("Drone", id).Warn("Could not parse locations of stations")
 	}
 
-	return &drone{id, statusOff, stations, 0, config.StationRepo}
+	randomSource := config.RandomSource
+	if randomSource == nil {
+		randomSource = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+
+	return &drone{
+		id:           id,
+		status:       statusOff,
+		stations:     stations,
+		battery:      1,
+		stationRepo:  config.StationRepo,
+		randomSource: randomSource,
+		random:       rand.New(randomSource),
+	}
 }
 
 // NewDroneWithDefaults creates a drone with default dependencies (backward compatible)
@@ -74,11 +112,48 @@ func (d *drone) IsOn() bool {
 }
 
 func (d *drone) HasMemory() bool {
-	return d.trafficReports <= maxMemory
+	return len(d.reports) <= MaxMemory
+}
+
+func (d *drone) Battery() float64 {
+	return d.battery
+}
+
+func (d *drone) Reports() []store.TrafficReport {
+	return d.reports
+}
+
+func (d *drone) Snapshot() DroneState {
+	randomState, err := d.randomSource.MarshalBinary()
+	if err != nil {
+		logrus.WithField("Drone", d.id).Errorf("Could not save random state: %s", err)
+	}
+
+	return DroneState{
+		Status:      d.status,
+		Reports:     append([]store.TrafficReport(nil), d.reports...),
+		Battery:     d.battery,
+		RandomState: randomState,
+	}
+}
+
+func (d *drone) Restore(state DroneState) error {
+	if err := d.randomSource.UnmarshalBinary(state.RandomState); err != nil {
+		return fmt.Errorf("could not restore random state of drone %d: %w", d.id, err)
+	}
+
+	d.status = state.Status
+	d.reports = append([]store.TrafficReport(nil), state.Reports...)
+	d.battery = state.Battery
+	return nil
 }
 
 func (d *drone) Start() {
-	d.trafficReports = 0
+	d.reports = nil
+	// a drone restarted with a flat battery has it swapped for a charged one
+	if d.battery <= 0 {
+		d.battery = 1
+	}
 	d.status = statusOn
 	logrus.WithField("Drone", d.id).Info("On")
 }
@@ -91,12 +166,18 @@ func (d *drone) Move(location, nextLocation store.Location) store.Location {
 		return location
 	}
 
-	if d.trafficReports >= maxMemory {
+	if len(d.reports) >= MaxMemory {
 		logger.Error("Out of memory")
 		d.ShutDown()
 		return location
 	}
 
+	if d.battery <= 0 {
+		logger.Error("Battery flat")
+		d.ShutDown()
+		return location
+	}
+
 	logger = logger.WithField("To", fmt.Sprintf("(%f, %f)", nextLocation.Latitude, nextLocation.Longitude))
 	if location == nextLocation {
 		logger.Info("Lifted off")
@@ -105,12 +186,15 @@ func (d *drone) Move(location, nextLocation store.Location) store.Location {
 	}
 
 	travelTime := nextLocation.Time.Sub(location.Time)
-	time.Sleep(travelTime)
-
 	previousLocation := location
 	location = nextLocation
 
 	d.checkTrafficAtNearbyStations(location, d.calculateCurrentSpeed(previousLocation, location, travelTime))
+	_, distanceInKm := haversine.Distance(
+		haversine.Coord{Lat: previousLocation.Latitude, Lon: previousLocation.Longitude},
+		haversine.Coord{Lat: location.Latitude, Lon: location.Longitude},
+	)
+	d.battery = max(0, d.battery-distanceInKm/BatteryRangeInKm)
 	return location
 }
 
@@ -133,19 +217,34 @@ func (d *drone) checkTrafficAtNearbyStations(location store.Location, currentSpe
 			haversine.Coord{Lat: station.Latitude, Lon: station.Longitude},
 		)
 
-		if distanceInKm <= maxVisibilityInKm {
-			logrus.WithField("Drone", d.id).
-				WithField("Speed", fmt.Sprintf("%f km/h", currentSpeedInKph)).
-				WithField("Station", station.Name).
-				WithField("Time", strings.Split(location.Time.String(), " ")[1]).
-				WithField("Traffic", trafficScores[rand.Intn(len(trafficScores))]).
-				Info("Station in sight")
-
-			d.trafficReports++
+		if distanceInKm <= MaxVisibilityInKm {
+			report := store.TrafficReport{
+				DroneID:   d.id,
+				Station:   station.Name,
+				Latitude:  station.Latitude,
+				Longitude: station.Longitude,
+				Time:      location.Time,
+				SpeedKph:  currentSpeedInKph,
+				Condition: trafficScores[d.random.IntN(len(trafficScores))],
+				// a station is assessed better from overhead than from the edge of visibility
+				Confidence: 1 - distanceInKm/(2*MaxVisibilityInKm),
+			}
+			logReport(report)
+
+			d.reports = append(d.reports, report)
 		}
 	}
 }
 
+func logReport(report store.TrafficReport) {
+	logrus.WithField("Drone", report.DroneID).
+		WithField("Speed", fmt.Sprintf("%f km/h", report.SpeedKph)).
+		WithField("Station", report.Station).
+		WithField("Time", strings.Split(report.Time.String(), " ")[1]).
+		WithField("Traffic", report.Condition).
+		Info("Station in sight")
+}
+
 func (d *drone) ShutDown() {
 	d.status = statusOff
 	logrus.WithField("Drone", d.id).Info("Off")
@@ -69,6 +69,48 @@ func TestStart_Move_ShutDown(t *testing.T) {
 	assert.Equal(currentLocation, location)
 }
 
+func TestBattery(t *testing.T) {
+	assert := assert.New(t)
+	logrus.SetOutput(io.Discard)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given a drone on a full battery
+	drone := NewDrone(1234, DroneConfig{StationRepo: &store.MockStationRepository{
+		GetStationsFunc: func() ([]store.Station, error) { return nil, nil },
+	}})
+	drone.Start()
+	assert.Equal(1.0, drone.Battery())
+
+	// When it flies a quarter of its range, about 0.045 degrees of latitude per km
+	from := store.Location{Latitude: 51.5, Longitude: -0.1, Time: start}
+	to := store.Location{Latitude: 51.5 + BatteryRangeInKm/4/111.195, Longitude: -0.1, Time: start.Add(time.Minute)}
+	assert.Equal(to, drone.Move(from, to))
+	// Then a quarter of its battery should be used
+	assert.InDelta(0.75, drone.Battery(), 0.001)
+
+	// When it flies further than its battery lasts
+	far := store.Location{Latitude: 52.5, Longitude: -0.1, Time: start.Add(time.Hour)}
+	assert.Equal(far, drone.Move(to, far))
+	// Then its battery should be flat, and it should shut down rather than move again
+	assert.Equal(0.0, drone.Battery())
+	assert.Equal(far, drone.Move(far, from))
+	assert.False(drone.IsOn())
+
+	// When it is restarted
+	drone.Start()
+	// Then its flat battery should be swapped for a charged one
+	assert.Equal(1.0, drone.Battery())
+
+	// When its state is saved and restored into another drone
+	drone.Move(from, to)
+	restored := NewDrone(1234, DroneConfig{StationRepo: &store.MockStationRepository{
+		GetStationsFunc: func() ([]store.Station, error) { return nil, nil },
+	}})
+	assert.NoError(restored.Restore(drone.Snapshot()))
+	// Then it should have the same charge left
+	assert.Equal(drone.Battery(), restored.Battery())
+}
+
 func TestCheckTrafficAtNearbyStations(t *testing.T) {
 	// This test would require more refactoring of the drone implementation
 	// to make checkTrafficAtNearbyStations testable through dependency injection
@@ -83,7 +125,7 @@ func TestMaxMemory(t *testing.T) {
 	// Create mock station repository with multiple stations to trigger memory limit
 	mockStationRepo := &store.MockStationRepository{
 		GetStationsFunc: func() ([]store.Station, error) {
-			stations := make([]store.Station, 15) // More than maxMemory (10)
+			stations := make([]store.Station, 15) // More than MaxMemory (10)
 			for i := 0; i < 15; i++ {
 				stations[i] = store.Station{
 					Name:      "Station" + string(rune(i)),
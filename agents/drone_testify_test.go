@@ -18,7 +18,7 @@ func TestDrone_WithTestifyMock(t *testing.T) {
 
 	// Set up expectations
 	expectedStations := []store.Station{
-		{"Test Station", 51.5074, -0.1278},
+		{Name: "Test Station", Latitude: 51.5074, Longitude: -0.1278},
 	}
 	mockStationRepo.On("GetStations").Return(expectedStations, nil)
 
@@ -44,8 +44,8 @@ func TestDrone_WithTestifyMockAdvanced(t *testing.T) {
 
 	// Set up expectations with specific call count
 	expectedStations := []store.Station{
-		{"Station 1", 51.5074, -0.1278},
-		{"Station 2", 51.5174, -0.1378},
+		{Name: "Station 1", Latitude: 51.5074, Longitude: -0.1278},
+		{Name: "Station 2", Latitude: 51.5174, Longitude: -0.1378},
 	}
 	mockStationRepo.On("GetStations").Return(expectedStations, nil).Once()
 
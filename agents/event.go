@@ -0,0 +1,64 @@
+package agents
+
+import (
+	"drone_simulation/store"
+	"time"
+)
+
+// EventKind defines what happened in a simulation event
+type EventKind string
+
+// Kinds of events emitted by the dispatcher
+const (
+	EventLaunch   EventKind = "launch"
+	EventMove     EventKind = "move"
+	EventReport   EventKind = "report"
+	EventRestart  EventKind = "restart"
+	EventShutDown EventKind = "shutdown"
+	// EventFusion fuses the reports of several drones at a station, it belongs to no drone
+	EventFusion EventKind = "fusion"
+)
+
+// Reasons of restart events, for which the drone shut itself down
+const (
+	RestartOutOfMemory = "out of memory"
+	RestartBatteryFlat = "battery flat"
+)
+
+// Event defines something that happened to a drone during a simulation
+type Event struct {
+	Seq      int                  `json:"seq"`
+	Kind     EventKind            `json:"kind"`
+	DroneID  int                  `json:"drone"`
+	Time     time.Time            `json:"time"`
+	Location *store.Location      `json:"location,omitempty"`
+	Report   *store.TrafficReport `json:"report,omitempty"`
+	Reason   string               `json:"reason,omitempty"`
+	// Error is the error that ended the flight of a drone, on its shutdown
+	Error  string  `json:"error,omitempty"`
+	Fusion *Fusion `json:"fusion,omitempty"`
+	// Battery is the charge left to the drone after a move, from 0 when flat to 1 when full
+	Battery float64 `json:"battery,omitempty"`
+}
+
+// EventSink defines a consumer of the events emitted by the dispatcher
+type EventSink interface {
+	Handle(event Event)
+}
+
+// EventSinkFunc adapts a function to an EventSink
+type EventSinkFunc func(event Event)
+
+// Handle calls f(event)
+func (f EventSinkFunc) Handle(event Event) {
+	f(event)
+}
+
+// ReportSink returns an EventSink handing the report of every report event to handle, e.g. to aggregate them
+func ReportSink(handle func(report store.TrafficReport)) EventSink {
+	return EventSinkFunc(func(event Event) {
+		if event.Kind == EventReport && event.Report != nil {
+			handle(*event.Report)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package agents
+
+import (
+	"drone_simulation/store"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FusionStrategy decides the traffic condition at a station from the reports of several drones
+type FusionStrategy interface {
+	// Name names the strategy in events and logs
+	Name() string
+	// Fuse returns the condition of reports ordered by time
+	Fuse(reports []store.TrafficReport) string
+}
+
+// Fusion strategies
+var (
+	// LatestWins keeps the condition reported last
+	LatestWins FusionStrategy = latestWins{}
+	// MajorityVote keeps the condition most reported, the one reported last among the most reported on a tie
+	MajorityVote FusionStrategy = majorityVote{}
+	// ConfidenceWeighted keeps the condition of the highest total confidence, voting by majority on a tie
+	ConfidenceWeighted FusionStrategy = confidenceWeighted{}
+)
+
+// FusionStrategies are the fusion strategies by name
+var FusionStrategies = map[string]FusionStrategy{
+	LatestWins.Name():         LatestWins,
+	MajorityVote.Name():       MajorityVote,
+	ConfidenceWeighted.Name(): ConfidenceWeighted,
+}
+
+// FusionConfig defines how the reports of several drones at a station are fused
+type FusionConfig struct {
+	Strategy FusionStrategy
+	// Window is how close in time the reports of different drones must be to be fused
+	Window time.Duration
+}
+
+// Fusion records the reports fused into the condition of a fusion event
+type Fusion struct {
+	Strategy string `json:"strategy"`
+	// Merged are the reports fused, the latest of each drone within the window, ordered by time
+	Merged []store.TrafficReport `json:"merged"`
+	// Overridden are the merged reports whose condition was not kept
+	Overridden []store.TrafficReport `json:"overridden,omitempty"`
+}
+
+type latestWins struct{}
+
+func (latestWins) Name() string { return "latest" }
+
+func (latestWins) Fuse(reports []store.TrafficReport) string {
+	return reports[len(reports)-1].Condition
+}
+
+type majorityVote struct{}
+
+func (majorityVote) Name() string { return "majority" }
+
+func (majorityVote) Fuse(reports []store.TrafficReport) string {
+	condition, _ := vote(reports, func(store.TrafficReport) float64 { return 1 })
+	return condition
+}
+
+type confidenceWeighted struct{}
+
+func (confidenceWeighted) Name() string { return "weighted" }
+
+func (confidenceWeighted) Fuse(reports []store.TrafficReport) string {
+	condition, tie := vote(reports, func(report store.TrafficReport) float64 { return report.Confidence })
+	if tie {
+		return MajorityVote.Fuse(reports)
+	}
+	return condition
+}
+
+// vote returns the condition of the highest total weight, the one reported last among those of the highest, and
+// whether several conditions share the highest
+func vote(reports []store.TrafficReport, weight func(store.TrafficReport) float64) (string, bool) {
+	totals := map[string]float64{}
+	for _, report := range reports {
+		totals[report.Condition] += weight(report)
+	}
+	best := ""
+	for _, report := range reports {
+		if best == "" || totals[report.Condition] >= totals[best] {
+			best = report.Condition
+		}
+	}
+	for condition, total := range totals {
+		if condition != best && total == totals[best] {
+			return best, true
+		}
+	}
+	return best, false
+}
+
+// fuser keeps the recent reports made at every station to fuse those of several drones
+type fuser struct {
+	config FusionConfig
+	recent map[string][]store.TrafficReport
+}
+
+func newFuser(config FusionConfig) *fuser {
+	return &fuser{config: config, recent: map[string][]store.TrafficReport{}}
+}
+
+// add records a report and returns a fusion event of the latest report of every drone at its station within the
+// window, or nil if no other drone reported there within it
+func (f *fuser) add(report store.TrafficReport) *Event {
+	// the reports kept are those within the window of the newest one made at the station, whatever the order they
+	// arrive in, so that what is fused only depends on the times of the reports
+	newest := report.Time
+	for _, other := range f.recent[report.Station] {
+		if other.Time.After(newest) {
+			newest = other.Time
+		}
+	}
+	var recent []store.TrafficReport
+	for _, other := range f.recent[report.Station] {
+		if newest.Sub(other.Time) <= f.config.Window {
+			recent = append(recent, other)
+		}
+	}
+	recent = append(recent, report)
+	f.recent[report.Station] = recent
+
+	// the latest report of every drone within the window of the new one
+	latest := map[int]store.TrafficReport{}
+	for _, other := range recent {
+		if other.Time.Sub(report.Time).Abs() > f.config.Window {
+			continue
+		}
+		if previous, ok := latest[other.DroneID]; !ok || !other.Time.Before(previous.Time) {
+			latest[other.DroneID] = other
+		}
+	}
+	if len(latest) < 2 {
+		return nil
+	}
+
+	merged := make([]store.TrafficReport, 0, len(latest))
+	for _, other := range latest {
+		merged = append(merged, other)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if !merged[i].Time.Equal(merged[j].Time) {
+			return merged[i].Time.Before(merged[j].Time)
+		}
+		return merged[i].DroneID < merged[j].DroneID
+	})
+
+	fused := store.TrafficReport{
+		Station:   report.Station,
+		Latitude:  report.Latitude,
+		Longitude: report.Longitude,
+		Time:      merged[len(merged)-1].Time,
+		Condition: f.config.Strategy.Fuse(merged),
+	}
+	fusion := &Fusion{Strategy: f.config.Strategy.Name(), Merged: merged}
+	for _, other := range merged {
+		if other.Condition != fused.Condition {
+			fusion.Overridden = append(fusion.Overridden, other)
+		}
+	}
+	return &Event{Kind: EventFusion, Time: fused.Time, Report: &fused, Fusion: fusion}
+}
+
+func logFusion(event Event) {
+	logrus.WithField("Station", event.Report.Station).
+		WithField("Time", event.Time.Format(time.TimeOnly)).
+		WithField("Traffic", event.Report.Condition).
+		WithField("Strategy", event.Fusion.Strategy).
+		WithField("Merged", len(event.Fusion.Merged)).
+		WithField("Overridden", len(event.Fusion.Overridden)).
+		Info("Fused reports")
+}
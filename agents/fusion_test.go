@@ -0,0 +1,170 @@
+package agents
+
+import (
+	"drone_simulation/store"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFusionStrategies(t *testing.T) {
+	report := func(condition string, confidence float64) store.TrafficReport {
+		return store.TrafficReport{Condition: condition, Confidence: confidence}
+	}
+
+	testCases := []struct {
+		name     string
+		strategy FusionStrategy
+		reports  []store.TrafficReport
+		expected string
+	}{
+		{
+			name:     "LatestWins should keep the condition reported last",
+			strategy: LatestWins,
+			reports:  []store.TrafficReport{report(store.TrafficHeavy, 1), report(store.TrafficHeavy, 1), report(store.TrafficLight, 0.5)},
+			expected: store.TrafficLight,
+		},
+		{
+			name:     "MajorityVote should keep the condition most reported",
+			strategy: MajorityVote,
+			reports:  []store.TrafficReport{report(store.TrafficHeavy, 0.5), report(store.TrafficLight, 1), report(store.TrafficHeavy, 0.5)},
+			expected: store.TrafficHeavy,
+		},
+		{
+			name:     "MajorityVote should keep the condition reported last on a tie",
+			strategy: MajorityVote,
+			reports:  []store.TrafficReport{report(store.TrafficLight, 1), report(store.TrafficHeavy, 1)},
+			expected: store.TrafficHeavy,
+		},
+		{
+			name:     "ConfidenceWeighted should keep the condition of the highest total confidence",
+			strategy: ConfidenceWeighted,
+			reports:  []store.TrafficReport{report(store.TrafficHeavy, 0.5), report(store.TrafficLight, 0.9), report(store.TrafficHeavy, 0.3)},
+			expected: store.TrafficLight,
+		},
+		{
+			name:     "ConfidenceWeighted should vote by majority on a tie",
+			strategy: ConfidenceWeighted,
+			reports:  []store.TrafficReport{report(store.TrafficHeavy, 0), report(store.TrafficHeavy, 0), report(store.TrafficLight, 0)},
+			expected: store.TrafficHeavy,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.strategy.Fuse(testCase.reports))
+		})
+	}
+}
+
+func TestFuser(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	report := func(id int, seconds int, condition string) store.TrafficReport {
+		return store.TrafficReport{DroneID: id, Station: "Bank", Time: start.Add(time.Duration(seconds) * time.Second), Condition: condition}
+	}
+
+	// Given a fuser of the reports made within 10 seconds
+	fuser := newFuser(FusionConfig{Strategy: MajorityVote, Window: 10 * time.Second})
+
+	// Then the reports of a single drone should not be fused
+	assert.Nil(fuser.add(report(1, 0, store.TrafficHeavy)))
+	assert.Nil(fuser.add(report(1, 5, store.TrafficLight)))
+
+	// And the latest report of every drone within the window should be
+	event := fuser.add(report(2, 8, store.TrafficLight))
+	if assert.NotNil(event) {
+		assert.Equal(EventFusion, event.Kind)
+		assert.Equal(start.Add(8*time.Second), event.Time)
+		assert.Equal(store.TrafficLight, event.Report.Condition)
+		assert.Equal("majority", event.Fusion.Strategy)
+		assert.Equal([]store.TrafficReport{report(1, 5, store.TrafficLight), report(2, 8, store.TrafficLight)}, event.Fusion.Merged)
+		assert.Empty(event.Fusion.Overridden)
+	}
+
+	event = fuser.add(report(3, 12, store.TrafficHeavy))
+	if assert.NotNil(event) {
+		assert.Equal(store.TrafficLight, event.Report.Condition)
+		assert.Len(event.Fusion.Merged, 3)
+		assert.Equal([]store.TrafficReport{report(3, 12, store.TrafficHeavy)}, event.Fusion.Overridden)
+	}
+
+	// And reports outside the window should be forgotten
+	assert.Nil(fuser.add(report(1, 30, store.TrafficModerate)))
+}
+
+func TestFuser_OutOfOrder(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	report := func(id int, seconds int, condition string) store.TrafficReport {
+		return store.TrafficReport{DroneID: id, Station: "Bank", Time: start.Add(time.Duration(seconds) * time.Second), Condition: condition}
+	}
+
+	// Given a fuser of the reports made within 10 seconds
+	fuser := newFuser(FusionConfig{Strategy: MajorityVote, Window: 10 * time.Second})
+
+	// When a report arrives ahead of another drone's, then one of a third drone made in between
+	assert.Nil(fuser.add(report(1, 0, store.TrafficHeavy)))
+	assert.Nil(fuser.add(report(2, 14, store.TrafficLight)))
+	event := fuser.add(report(3, 4, store.TrafficHeavy))
+
+	// Then the first report should be forgotten, as it is out of the window of the newest one, and the late report
+	// fused with the newest
+	if assert.NotNil(event) {
+		assert.Equal([]store.TrafficReport{report(3, 4, store.TrafficHeavy), report(2, 14, store.TrafficLight)}, event.Fusion.Merged)
+		assert.Equal(store.TrafficLight, event.Report.Condition)
+	}
+
+	// When a later report arrives out of the window of the newest one
+	event = fuser.add(report(1, 2, store.TrafficModerate))
+	// Then it should only be fused with the reports within its own window
+	if assert.NotNil(event) {
+		assert.Equal([]store.TrafficReport{report(1, 2, store.TrafficModerate), report(3, 4, store.TrafficHeavy)}, event.Fusion.Merged)
+	}
+}
+
+func TestDispatcher_Fusion(t *testing.T) {
+	assert := assert.New(t)
+	NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given two drones flying the same route over a station on a synchronized clock, fused by the latest report
+	recorder := NewRecorder()
+	var fusions []Event
+	clock := NewClock(ClockConfig{Synchronized: true, Start: start, Speed: 1000})
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{
+		Clock: clock,
+		Sinks: []EventSink{recorder, EventSinkFunc(func(event Event) {
+			if event.Kind == EventFusion {
+				fusions = append(fusions, event)
+			}
+		})},
+		Fusion: &FusionConfig{Strategy: LatestWins, Window: time.Minute},
+	})
+	for _, id := range []int{1, 2} {
+		drone := NewDrone(id, DroneConfig{
+			StationRepo: &store.MockStationRepository{
+				GetStationsFunc: func() ([]store.Station, error) {
+					return []store.Station{{Name: "Test Station", Latitude: 51.5, Longitude: -0.1}}, nil
+				},
+			},
+			RandomSource: rand.NewPCG(42, uint64(id)),
+		})
+		assert.NoError(dispatcher.AddDrone(drone, testRouteRepo(testRoute(id, start, 5, time.Second)), time.Time{}))
+	}
+
+	// When
+	dispatcher.Wait()
+
+	// Then every report after the first one of the other drone should be fused with it
+	assert.Len(recorder.Reports(), 10)
+	assert.NotEmpty(fusions)
+	for _, fusion := range fusions {
+		assert.Equal(0, fusion.DroneID)
+		assert.Len(fusion.Fusion.Merged, 2)
+		assert.Equal(fusion.Fusion.Merged[1].Condition, fusion.Report.Condition)
+		assert.Greater(fusion.Seq, 0)
+	}
+}
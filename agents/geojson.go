@@ -0,0 +1,58 @@
+package agents
+
+import (
+	"drone_simulation/store"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// recordSeparator starts every record of a GeoJSON text sequence, as defined by RFC 8142
+const recordSeparator = 0x1e
+
+// GeoJSONSink is an EventSink writing every traffic report as a GeoJSON Point feature in a GeoJSON text sequence
+// (RFC 8142, usually named .geojsons), so that a web map can follow a simulation as it runs
+type GeoJSONSink struct {
+	mu      sync.Mutex
+	w       io.Writer
+	encoder *json.Encoder
+	err     error
+}
+
+// NewGeoJSONSink returns a sink writing report features to w
+func NewGeoJSONSink(w io.Writer) *GeoJSONSink {
+	return &GeoJSONSink{w: w, encoder: json.NewEncoder(w)}
+}
+
+// Handle writes the report of a report event
+func (s *GeoJSONSink) Handle(event Event) {
+	if event.Kind != EventReport || event.Report == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return
+	}
+	// the encoder ends every record with the line feed of the sequence
+	_, err := s.w.Write([]byte{recordSeparator})
+	if err == nil {
+		err = s.encoder.Encode(store.ReportFeature(*event.Report))
+	}
+	if err != nil {
+		s.err = err
+		logrus.WithField("Seq", event.Seq).Errorf("Could not write GeoJSON report: %s", err)
+	}
+}
+
+// Err returns the first error encountered while writing reports
+func (s *GeoJSONSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
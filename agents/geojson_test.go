@@ -0,0 +1,41 @@
+package agents
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoJSONSink(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given the events of a journaled run
+	events := journaledRun(t, 42)
+
+	// When they are handed to a GeoJSON sink
+	var buffer bytes.Buffer
+	sink := NewGeoJSONSink(&buffer)
+	for _, event := range events {
+		sink.Handle(event)
+	}
+	assert.NoError(sink.Err())
+
+	// Then every report should be written as a Point feature in a record of a GeoJSON text sequence
+	records := bytes.Split(buffer.Bytes(), []byte{recordSeparator})
+	assert.Empty(records[0], "the sequence should start with a record separator")
+	assert.Len(records[1:], 50)
+	for _, record := range records[1:] {
+		assert.True(bytes.HasSuffix(record, []byte("\n")), "every record should end with a line feed")
+		var feature struct {
+			Geometry struct {
+				Type string
+			}
+			Properties map[string]any
+		}
+		assert.NoError(json.Unmarshal(record, &feature))
+		assert.Equal("Point", feature.Geometry.Type)
+		assert.Equal("Test Station", feature.Properties["station"])
+	}
+}
@@ -0,0 +1,263 @@
+package agents
+
+import (
+	"bufio"
+	"bytes"
+	"drone_simulation/store"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Journal is an EventSink appending every event to a writer as one JSON object per line
+type Journal struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewJournal returns a journal appending events to w
+func NewJournal(w io.Writer) *Journal {
+	return &Journal{encoder: json.NewEncoder(w)}
+}
+
+// Handle appends an event to the journal
+func (j *Journal) Handle(event Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return
+	}
+	if err := j.encoder.Encode(event); err != nil {
+		j.err = err
+		logrus.WithField("Seq", event.Seq).Errorf("Could not write to journal: %s", err)
+	}
+}
+
+// Err returns the first error encountered while writing the journal
+func (j *Journal) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.err
+}
+
+// ReadJournal returns the events of a journal in the order they were written
+func ReadJournal(r io.Reader) ([]Event, error) {
+	var events []Event
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		// a line is read whole, however long the fusion or report it holds
+		text, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(text)) > 0 {
+			var event Event
+			if err := json.Unmarshal(text, &event); err != nil {
+				return nil, fmt.Errorf("journal line %d: %w", line, err)
+			}
+			if err := validateEvent(event); err != nil {
+				return nil, fmt.Errorf("journal line %d: %w", line, err)
+			}
+			events = append(events, event)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events, nil
+}
+
+// validateEvent checks that an event has the fields its kind requires
+func validateEvent(event Event) error {
+	switch event.Kind {
+	case EventLaunch, EventRestart, EventShutDown:
+	case EventMove:
+		if event.Location == nil {
+			return fmt.Errorf("%s event without location", event.Kind)
+		}
+	case EventReport:
+		if event.Report == nil {
+			return fmt.Errorf("%s event without report", event.Kind)
+		}
+	case EventFusion:
+		if event.Report == nil || event.Fusion == nil {
+			return fmt.Errorf("%s event without report or fusion", event.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown event kind %q", event.Kind)
+	}
+	return nil
+}
+
+// Replay reproduces the log output of the drones of a journaled run, handing every event to sink as well if it is
+// not nil
+func Replay(events []Event, sink EventSink) {
+	// a drone lifts off at the first waypoint after its launch, and at a waypoint given twice in a row, and logs
+	// its moves at the time it leaves the last waypoint
+	lastLocations := map[int]store.Location{}
+	for _, event := range events {
+		logger := logrus.WithField("Drone", event.DroneID)
+		switch event.Kind {
+		case EventLaunch:
+			delete(lastLocations, event.DroneID)
+			logger.Info("On")
+		case EventMove:
+			last, ok := lastLocations[event.DroneID]
+			if !ok {
+				last = *event.Location
+			}
+			logger = logger.WithField("Time", strings.Split(last.Time.String(), " ")[1]).
+				WithField("To", fmt.Sprintf("(%f, %f)", event.Location.Latitude, event.Location.Longitude))
+			if last == *event.Location {
+				logger.Info("Lifted off")
+			} else {
+				logger.Info("Flying")
+			}
+			lastLocations[event.DroneID] = *event.Location
+		case EventReport:
+			logReport(*event.Report)
+		case EventRestart:
+			timeLogger := logger.WithField("Time", strings.Split(event.Time.String(), " ")[1])
+			switch event.Reason {
+			case RestartOutOfMemory:
+				timeLogger.Error("Out of memory")
+			case RestartBatteryFlat:
+				timeLogger.Error("Battery flat")
+			}
+			logger.Info("Off")
+			logger.Info("Trying to restart")
+			logger.Info("On")
+		case EventShutDown:
+			switch event.Reason {
+			case "removed":
+				logger.Info("Removed from fleet")
+			case "invalid route":
+				logger.Errorf("Could not parse route, aborting: %s", event.Error)
+			case "restart failed":
+				logger.Error("Restart failed, aborting")
+			case "empty route":
+				logger.Error("No route after start time, aborting")
+			}
+			logger.Info("Off")
+		case EventFusion:
+			logFusion(event)
+		}
+
+		if sink != nil {
+			sink.Handle(event)
+		}
+	}
+}
+
+// DiffJournals compares the events of each drone in two journals and describes every difference. Events of
+// different drones are compared independently, since their interleaving depends on goroutine scheduling, and
+// fusions, which belong to no drone, are matched by station and time.
+func DiffJournals(a, b []Event) []string {
+	groupsA, groupsB := groupEvents(a), groupEvents(b)
+
+	keys := map[journalKey]bool{}
+	for key := range groupsA {
+		keys[key] = true
+	}
+	for key := range groupsB {
+		keys[key] = true
+	}
+	sortedKeys := make([]journalKey, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Slice(sortedKeys, func(i, j int) bool { return sortedKeys[i].less(sortedKeys[j]) })
+
+	var diffs []string
+	for _, key := range sortedKeys {
+		eventsA, eventsB := groupsA[key], groupsB[key]
+		for i := 0; i < len(eventsA) || i < len(eventsB); i++ {
+			switch {
+			case i >= len(eventsA):
+				diffs = append(diffs, fmt.Sprintf("%s event %d: only in second journal: %s", key, i, describe(eventsB[i])))
+			case i >= len(eventsB):
+				diffs = append(diffs, fmt.Sprintf("%s event %d: only in first journal: %s", key, i, describe(eventsA[i])))
+			case describe(eventsA[i]) != describe(eventsB[i]):
+				diffs = append(diffs, fmt.Sprintf("%s event %d: %s != %s", key, i, describe(eventsA[i]), describe(eventsB[i])))
+			}
+		}
+	}
+	return diffs
+}
+
+// journalKey identifies the events of a journal compared together: those of a drone, or the fusions at a station
+// at a time
+type journalKey struct {
+	drone   int
+	station string
+	time    time.Time
+}
+
+func (k journalKey) String() string {
+	if k.station != "" {
+		return fmt.Sprintf("fusion at %s %s", k.station, k.time.Format("15:04:05"))
+	}
+	return fmt.Sprintf("drone %d", k.drone)
+}
+
+// less orders the drones by ID, followed by the fusions by time and station
+func (k journalKey) less(other journalKey) bool {
+	if (k.station == "") != (other.station == "") {
+		return k.station == ""
+	}
+	if !k.time.Equal(other.time) {
+		return k.time.Before(other.time)
+	}
+	if k.station != other.station {
+		return k.station < other.station
+	}
+	return k.drone < other.drone
+}
+
+// groupEvents groups events by journalKey, fusions at the same station and time ordered by description since
+// their order depends on the interleaving of the drones
+func groupEvents(events []Event) map[journalKey][]Event {
+	groups := map[journalKey][]Event{}
+	for _, event := range events {
+		key := journalKey{drone: event.DroneID}
+		if event.Kind == EventFusion {
+			key = journalKey{station: event.Report.Station, time: event.Time}
+		}
+		groups[key] = append(groups[key], event)
+	}
+	for key, group := range groups {
+		if key.station != "" {
+			sort.SliceStable(group, func(i, j int) bool { return describe(group[i]) < describe(group[j]) })
+		}
+	}
+	return groups
+}
+
+// describe returns an event without its sequence number, which differs between runs
+func describe(event Event) string {
+	description := fmt.Sprintf("%s at %s", event.Kind, event.Time.Format("15:04:05"))
+	if event.Location != nil {
+		description += fmt.Sprintf(" to (%f, %f)", event.Location.Latitude, event.Location.Longitude)
+	}
+	if event.Report != nil {
+		description += fmt.Sprintf(" %s %s at %f km/h", event.Report.Station, event.Report.Condition, event.Report.SpeedKph)
+	}
+	if event.Reason != "" {
+		description += " (" + event.Reason + ")"
+	}
+	if event.Fusion != nil {
+		description += fmt.Sprintf(" by %s of %d reports", event.Fusion.Strategy, len(event.Fusion.Merged))
+	}
+	return description
+}
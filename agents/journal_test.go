@@ -0,0 +1,206 @@
+package agents
+
+import (
+	"bytes"
+	"drone_simulation/store"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// journaledRun flies two seeded drones past a station and returns the journal of the run
+func journaledRun(t *testing.T, seed uint64) []Event {
+	NewTestHelper()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	var buffer bytes.Buffer
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{
+		Clock: NewClock(ClockConfig{Speed: 1000}),
+		Sinks: []EventSink{NewJournal(&buffer)},
+	})
+	for _, id := range []int{1, 2} {
+		drone := NewDrone(id, DroneConfig{
+			StationRepo: &store.MockStationRepository{
+				GetStationsFunc: func() ([]store.Station, error) {
+					return []store.Station{{Name: "Test Station", Latitude: 51.5, Longitude: -0.1}}, nil
+				},
+			},
+			RandomSource: rand.NewPCG(seed, uint64(id)),
+		})
+		assert.NoError(t, dispatcher.AddDrone(drone, testRouteRepo(testRoute(id, start, 25, time.Second)), time.Time{}))
+	}
+	dispatcher.Wait()
+
+	events, err := ReadJournal(&buffer)
+	assert.NoError(t, err)
+	return events
+}
+
+func TestJournal_ReadJournal(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a journaled run
+	events := journaledRun(t, 42)
+
+	// Then the journal should contain every kind of event, numbered in order
+	kinds := map[EventKind]int{}
+	for i, event := range events {
+		assert.Equal(i+1, event.Seq)
+		kinds[event.Kind]++
+	}
+	assert.Equal(2, kinds[EventLaunch])
+	assert.Equal(50, kinds[EventMove])
+	assert.Equal(50, kinds[EventReport])
+	assert.Equal(4, kinds[EventRestart])
+	assert.Equal(2, kinds[EventShutDown])
+}
+
+func TestJournal_DiffJournals(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given two runs with the same seed
+	// Then their journals should not differ
+	assert.Empty(DiffJournals(journaledRun(t, 42), journaledRun(t, 42)))
+
+	// Given two runs with different seeds
+	// Then their reported traffic conditions should differ
+	assert.NotEmpty(DiffJournals(journaledRun(t, 42), journaledRun(t, 43)))
+}
+
+func TestJournal_ReadJournalInvalid(t *testing.T) {
+	testCases := []struct {
+		name          string
+		journal       string
+		expectedError string
+	}{
+		{name: "ReadJournal() should reject a line that is not JSON", journal: "{\"seq\":1,\"kind\":\"launch\"}\nnot json\n", expectedError: "journal line 2"},
+		{name: "ReadJournal() should reject a move without location", journal: "{\"seq\":1,\"kind\":\"launch\"}\n{\"kind\":\"move\"}\n", expectedError: "journal line 2: move event without location"},
+		{name: "ReadJournal() should reject a report without report", journal: "{\"kind\":\"report\",\"drone\":1}\n", expectedError: "journal line 1: report event without report"},
+		{name: "ReadJournal() should reject a fusion without fusion", journal: "{\"kind\":\"fusion\",\"report\":{\"station\":\"Victoria\"}}\n", expectedError: "journal line 1: fusion event without report or fusion"},
+		{name: "ReadJournal() should reject an unknown kind", journal: "{\"seq\":1}\n", expectedError: "journal line 1: unknown event kind"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When
+			_, err := ReadJournal(bytes.NewBufferString(testCase.journal))
+
+			// Then it should fail with the line number rather than panic on replay
+			assert.ErrorContains(t, err, testCase.expectedError)
+		})
+	}
+}
+
+func TestJournal_DiffJournalsFusion(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	fusion := func(station, condition string) Event {
+		report := store.TrafficReport{Station: station, Time: start, Condition: condition}
+		return Event{Kind: EventFusion, Time: start, Report: &report, Fusion: &Fusion{Strategy: "latest", Merged: []store.TrafficReport{report, report}}}
+	}
+
+	// Given two journals fusing reports at two stations at the same time, in a different order
+	a := []Event{fusion("Victoria", store.TrafficHeavy), fusion("Pimlico", store.TrafficLight)}
+	b := []Event{fusion("Pimlico", store.TrafficLight), fusion("Victoria", store.TrafficHeavy)}
+
+	// Then they should not differ
+	assert.Empty(DiffJournals(a, b))
+
+	// Given a fusion that differs
+	b[1] = fusion("Victoria", store.TrafficModerate)
+
+	// Then the difference should name its station and time
+	diffs := DiffJournals(a, b)
+	assert.Len(diffs, 1)
+	assert.Contains(diffs[0], "fusion at Victoria 07:47:55 event 0")
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := testRoute(1, start, 3, time.Second)
+	route[2] = route[1]
+
+	// Given the journal of a drone lifting off, flying, and given the same waypoint twice
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	events := []Event{{Kind: EventLaunch, DroneID: 1, Time: start}}
+	for i := range route {
+		events = append(events, Event{Kind: EventMove, DroneID: 1, Time: route[i].Time, Location: &route[i]})
+	}
+
+	// When it is replayed
+	var replayed []Event
+	Replay(events, EventSinkFunc(func(event Event) { replayed = append(replayed, event) }))
+
+	// Then it should log what the drone logged
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"On", "Lifted off", "Flying", "Lifted off"}, messages)
+	assert.Equal(t, events, replayed)
+}
+
+func TestReplay_Log(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given the journal of a run in which drones restart out of memory, land at the end of their route, and fail to
+	// read their route
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	events := journaledRun(t, 42)
+	var buffer bytes.Buffer
+	dispatcher := NewDispatcherWithConfig(DispatcherConfig{Sinks: []EventSink{NewJournal(&buffer)}})
+	routeRepo := &store.MockRouteRepository{GetRouteFunc: func(id int) ([]store.Location, error) {
+		return nil, errors.New("data/3.csv:2: column 2 (latitude): \"hello\" is not a number")
+	}}
+	assert.NoError(dispatcher.AddDrone(NewTestHelper().CreateTestDrone(3, &store.MockStationRepository{}), routeRepo, time.Time{}))
+	dispatcher.Wait()
+	failed, err := ReadJournal(&buffer)
+	assert.NoError(err)
+	events = append(events, failed...)
+	logged := droneLog(hook.AllEntries())
+	hook.Reset()
+
+	// When it is replayed
+	Replay(events, nil)
+
+	// Then it should log what each drone logged
+	replayed := droneLog(hook.AllEntries())
+	assert.Len(replayed, 3)
+	assert.Contains(replayed[1], "error Out of memory map[Drone:1 Time:07:48:04]")
+	assert.Contains(replayed[3], "error Could not parse route, aborting: data/3.csv:2: column 2 (latitude): \"hello\" is not a number map[Drone:3]")
+	assert.Equal(logged, replayed)
+}
+
+// droneLog returns the level, message and fields of the log entries of each drone, in order
+func droneLog(entries []*logrus.Entry) map[int][]string {
+	log := map[int][]string{}
+	for _, entry := range entries {
+		if id, ok := entry.Data["Drone"].(int); ok {
+			log[id] = append(log[id], fmt.Sprintf("%s %s %v", entry.Level, entry.Message, entry.Data))
+		}
+	}
+	return log
+}
+
+func TestReadJournal_LongLine(t *testing.T) {
+	// Given a journal holding a line longer than a bufio.Scanner reads
+	station := strings.Repeat("x", 100*1024)
+	journal := fmt.Sprintf("{\"seq\":1,\"kind\":\"report\",\"drone\":1,\"report\":{\"station\":%q}}\n", station)
+
+	// When it is read
+	events, err := ReadJournal(strings.NewReader(journal))
+
+	// Then its event should be read whole
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, station, events[0].Report.Station)
+}
@@ -0,0 +1,59 @@
+package agents
+
+import (
+	"drone_simulation/store"
+	"sort"
+	"sync"
+)
+
+// Recorder is an EventSink keeping the locations each drone actually flew to and the traffic reports it made,
+// to be exported once the simulation is over
+type Recorder struct {
+	mu      sync.Mutex
+	routes  map[int][]store.Location
+	reports []store.TrafficReport
+}
+
+// NewRecorder returns an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{routes: map[int][]store.Location{}}
+}
+
+// Handle records the location of a move event and the report of a report event
+func (r *Recorder) Handle(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case event.Kind == EventMove && event.Location != nil:
+		r.routes[event.DroneID] = append(r.routes[event.DroneID], *event.Location)
+	case event.Kind == EventReport && event.Report != nil:
+		r.reports = append(r.reports, *event.Report)
+	}
+}
+
+// Routes returns the locations flown by every drone, ordered by drone ID
+func (r *Recorder) Routes() [][]store.Location {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]int, 0, len(r.routes))
+	for id := range r.routes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	routes := make([][]store.Location, len(ids))
+	for i, id := range ids {
+		routes[i] = append([]store.Location(nil), r.routes[id]...)
+	}
+	return routes
+}
+
+// Reports returns the traffic reports of every drone in the order they were made
+func (r *Recorder) Reports() []store.TrafficReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]store.TrafficReport(nil), r.reports...)
+}
@@ -0,0 +1,47 @@
+package agents
+
+import (
+	"drone_simulation/store"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given the events of a journaled run
+	events := journaledRun(t, 42)
+
+	// When they are recorded
+	recorder := NewRecorder()
+	for _, event := range events {
+		recorder.Handle(event)
+	}
+
+	// Then the flown locations should be grouped by drone in order, and every report kept
+	routes := recorder.Routes()
+	assert.Len(routes, 2)
+	for i, route := range routes {
+		assert.Len(route, 25)
+		assert.Equal(i+1, route[0].DroneID)
+		assert.True(route[0].Time.Before(route[24].Time))
+	}
+	assert.Len(recorder.Reports(), 50)
+}
+
+func TestReportSink(t *testing.T) {
+	// Given the events of a journaled run
+	events := journaledRun(t, 42)
+
+	// When they are handed to a report sink
+	var reports []store.TrafficReport
+	sink := ReportSink(func(report store.TrafficReport) { reports = append(reports, report) })
+	for _, event := range events {
+		sink.Handle(event)
+	}
+
+	// Then only the reports should be handed on
+	assert.Len(t, reports, 50)
+	assert.Equal(t, "Test Station", reports[0].Station)
+}
@@ -22,8 +22,8 @@ func (h *TestHelper) CreateMockStationRepo() *store.MockStationRepository {
 	return &store.MockStationRepository{
 		GetStationsFunc: func() ([]store.Station, error) {
 			return []store.Station{
-				{"Test Station 1", 51.5074, -0.1278},
-				{"Test Station 2", 51.5174, -0.1378},
+				{Name: "Test Station 1", Latitude: 51.5074, Longitude: -0.1278},
+				{Name: "Test Station 2", Latitude: 51.5174, Longitude: -0.1378},
 			}, nil
 		},
 	}
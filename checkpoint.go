@@ -0,0 +1,58 @@
+package main
+
+import (
+	"drone_simulation/agents"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// saveCheckpoints saves a checkpoint of the dispatcher to path at every interval, until done is closed
+func saveCheckpoints(dispatcher agents.Dispatcher, path string, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			checkpoint := dispatcher.Checkpoint()
+			if err := agents.SaveCheckpoint(path, checkpoint); err != nil {
+				logrus.Errorf("Could not save checkpoint: %s", err)
+				continue
+			}
+			logrus.WithField("Time", checkpoint.Time.Format(time.TimeOnly)).WithField("Seq", checkpoint.Seq).Debug("Checkpoint saved")
+		}
+	}
+}
+
+// openJournal opens a journal file for writing. When resuming, the events published after the checkpoint
+// are dropped so that the resumed run appends exactly where the checkpoint left off.
+func openJournal(path string, resumed *agents.Checkpoint) (*os.File, error) {
+	if resumed == nil {
+		return os.Create(path)
+	}
+
+	events, err := readJournal(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	journal := agents.NewJournal(file)
+	for _, event := range events {
+		if event.Seq <= resumed.Seq {
+			journal.Handle(event)
+		}
+	}
+	if err := journal.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"drone_simulation/agents"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const controlHelp = `commands:
+  pause            freeze the simulated time
+  resume           let the simulated time run again
+  step             advance to the next waypoint any drone is waiting for
+  step <duration>  advance the simulated time by a duration, e.g. "step 1s"
+  speed <factor>   change the number of simulated seconds per real second, e.g. "speed 10"
+  list             show the state of every drone`
+
+// control applies the debugging commands read from r to the dispatcher, until r is closed
+func control(r io.Reader, w io.Writer, dispatcher agents.Dispatcher) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "pause", "p":
+			dispatcher.Pause()
+		case "resume", "r":
+			dispatcher.Resume()
+		case "step", "s":
+			if len(fields) == 1 {
+				dispatcher.StepWaypoint()
+				break
+			}
+
+			duration, err := time.ParseDuration(fields[1])
+			if err != nil {
+				fmt.Fprintf(w, "invalid duration %q: %s\n", fields[1], err)
+				continue
+			}
+			dispatcher.Step(duration)
+		case "speed":
+			if len(fields) != 2 {
+				fmt.Fprintln(w, controlHelp)
+				continue
+			}
+			speed, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil || speed <= 0 {
+				fmt.Fprintf(w, "invalid speed %q\n", fields[1])
+				continue
+			}
+			dispatcher.SetSpeed(speed)
+		case "list", "l":
+		default:
+			fmt.Fprintln(w, controlHelp)
+			continue
+		}
+
+		printDrones(w, dispatcher)
+	}
+}
+
+func printDrones(w io.Writer, dispatcher agents.Dispatcher) {
+	fmt.Fprintf(w, "simulated time %s at %gx\n", dispatcher.Now().Format(time.DateTime), dispatcher.Speed())
+	for _, drone := range dispatcher.ListDrones() {
+		fmt.Fprintf(w, "  drone %d: %s at (%f, %f), waypoint of %s\n",
+			drone.ID, drone.Status, drone.Location.Latitude, drone.Location.Longitude, drone.Location.Time.Format(time.TimeOnly))
+	}
+}
@@ -0,0 +1,216 @@
+// Package dashboard serves a live map of a simulation in a browser, fed by the events of the dispatcher
+package dashboard
+
+import (
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// clientBuffer is the number of events a client may lag behind before it is disconnected
+const clientBuffer = 256
+
+//go:embed static
+var static embed.FS
+
+// DroneStatus describes the state of a drone as last seen in the events
+type DroneStatus struct {
+	ID          int             `json:"id"`
+	Status      string          `json:"status"`
+	Location    *store.Location `json:"location,omitempty"`
+	Time        time.Time       `json:"time"`
+	Reports     int             `json:"reports"`
+	LastStation string          `json:"last_station,omitempty"`
+}
+
+// StationStatus describes a station and the traffic reported at it
+type StationStatus struct {
+	Name      string              `json:"name"`
+	Latitude  float64             `json:"latitude"`
+	Longitude float64             `json:"longitude"`
+	Lines     []string            `json:"lines,omitempty"`
+	Counts    store.TrafficCounts `json:"counts"`
+	// Latest is the last report made at the station, if any
+	Latest *store.TrafficReport `json:"latest,omitempty"`
+}
+
+// Server is an EventSink serving a single-page map of the drones and the traffic they report, streaming every event
+// to the browsers over server-sent events
+type Server struct {
+	mu         sync.Mutex
+	stations   []store.Station
+	aggregator *store.TrafficAggregator
+	drones     map[int]*DroneStatus
+	clients    map[chan []byte]struct{}
+	mux        *http.ServeMux
+}
+
+// NewServer returns a server of the map of given stations
+func NewServer(stations []store.Station) *Server {
+	s := &Server{
+		stations:   stations,
+		aggregator: store.NewTrafficAggregator(),
+		drones:     map[int]*DroneStatus{},
+		clients:    map[chan []byte]struct{}{},
+		mux:        http.NewServeMux(),
+	}
+
+	assets, _ := fs.Sub(static, "static")
+	s.mux.Handle("GET /", http.FileServerFS(assets))
+	s.mux.HandleFunc("GET /events", s.streamEvents)
+	s.mux.HandleFunc("GET /api/drones", s.listDrones)
+	s.mux.HandleFunc("GET /api/stations", s.listStations)
+	return s
+}
+
+// ServeHTTP serves the map, its assets, the event stream and the current state of the drones and stations
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Handle updates the state of the drones and stations with an event, and streams it to every browser
+func (s *Server) Handle(event agents.Event) {
+	if event.Kind == agents.EventReport && event.Report != nil {
+		s.aggregator.Add(*event.Report)
+	}
+
+	message, err := json.Marshal(event)
+	if err != nil {
+		logrus.WithField("Seq", event.Seq).Errorf("Could not stream event: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.update(event)
+	for client := range s.clients {
+		select {
+		case client <- []byte(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Kind, message)):
+		default:
+			// a browser too slow to keep up must not hold the dispatcher back, it reconnects and starts over
+			delete(s.clients, client)
+			close(client)
+		}
+	}
+}
+
+// update applies an event to the state of its drone
+func (s *Server) update(event agents.Event) {
+	if event.Kind == agents.EventFusion {
+		return
+	}
+	drone, ok := s.drones[event.DroneID]
+	if !ok {
+		drone = &DroneStatus{ID: event.DroneID}
+		s.drones[event.DroneID] = drone
+	}
+
+	drone.Time = event.Time
+	switch event.Kind {
+	case agents.EventLaunch, agents.EventRestart:
+		drone.Status = "on"
+	case agents.EventShutDown:
+		drone.Status = "off"
+	case agents.EventMove:
+		drone.Location = event.Location
+	case agents.EventReport:
+		drone.Reports++
+		drone.LastStation = event.Report.Station
+	}
+}
+
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	client := make(chan []byte, clientBuffer)
+	s.mu.Lock()
+	s.clients[client] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.clients[client]; ok {
+			delete(s.clients, client)
+			close(client)
+		}
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message, ok := <-client:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(message); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Drones returns the state of every drone seen in the events, ordered by ID
+func (s *Server) Drones() []DroneStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	drones := make([]DroneStatus, 0, len(s.drones))
+	for _, drone := range s.drones {
+		drones = append(drones, *drone)
+	}
+	sort.Slice(drones, func(i, j int) bool { return drones[i].ID < drones[j].ID })
+	return drones
+}
+
+// Stations returns every station and the traffic reported at it
+func (s *Server) Stations() []StationStatus {
+	stations := make([]StationStatus, len(s.stations))
+	for i, station := range s.stations {
+		stations[i] = StationStatus{
+			Name:      station.Name,
+			Latitude:  station.Latitude,
+			Longitude: station.Longitude,
+			Lines:     station.Lines,
+			Counts:    s.aggregator.Counts(station.Name),
+		}
+		if latest, ok := s.aggregator.Latest(station.Name); ok {
+			stations[i].Latest = &latest
+		}
+	}
+	return stations
+}
+
+func (s *Server) listDrones(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Drones())
+}
+
+func (s *Server) listStations(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Stations())
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logrus.Errorf("Could not write response: %s", err)
+	}
+}
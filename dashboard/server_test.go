@@ -0,0 +1,159 @@
+package dashboard
+
+import (
+	"bufio"
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testStart    = time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	testLocation = store.Location{DroneID: 5937, Latitude: 51.4965, Longitude: -0.1449, Time: testStart}
+	testReport   = store.TrafficReport{DroneID: 5937, Station: "Victoria", Latitude: 51.4965, Longitude: -0.1447, Time: testStart, Condition: store.TrafficHeavy}
+	testEvents   = []agents.Event{
+		{Seq: 1, Kind: agents.EventLaunch, DroneID: 5937, Time: testStart},
+		{Seq: 2, Kind: agents.EventMove, DroneID: 5937, Time: testStart, Location: &testLocation},
+		{Seq: 3, Kind: agents.EventReport, DroneID: 5937, Time: testStart, Report: &testReport},
+	}
+)
+
+func newTestServer() *Server {
+	return NewServer([]store.Station{
+		{Name: "Victoria", Latitude: 51.4965, Longitude: -0.1447},
+		{Name: "Pimlico", Latitude: 51.4893, Longitude: -0.1334},
+	})
+}
+
+func TestServer_Assets(t *testing.T) {
+	testCases := []struct {
+		name            string
+		path            string
+		expectedType    string
+		expectedContent string
+	}{
+		{name: "Server should serve the map", path: "/", expectedType: "text/html", expectedContent: "<title>Drone simulation</title>"},
+		{name: "Server should serve its script", path: "/app.js", expectedType: "javascript", expectedContent: "EventSource"},
+		{name: "Server should serve its style", path: "/style.css", expectedType: "text/css", expectedContent: "#map"},
+	}
+
+	server := httptest.NewServer(newTestServer())
+	defer server.Close()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When
+			response, err := http.Get(server.URL + testCase.path)
+			assert.NoError(t, err)
+			defer response.Body.Close()
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			// Then
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Contains(t, response.Header.Get("Content-Type"), testCase.expectedType)
+			assert.Contains(t, string(body), testCase.expectedContent)
+		})
+	}
+}
+
+func TestServer_State(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a server that handled the launch, move and report of a drone
+	dashboard := newTestServer()
+	for _, event := range testEvents {
+		dashboard.Handle(event)
+	}
+	server := httptest.NewServer(dashboard)
+	defer server.Close()
+
+	// When
+	var drones []DroneStatus
+	getJSON(t, server.URL+"/api/drones", &drones)
+	var stations []StationStatus
+	getJSON(t, server.URL+"/api/stations", &stations)
+
+	// Then
+	assert.Equal([]DroneStatus{{ID: 5937, Status: "on", Location: &testLocation, Time: testStart, Reports: 1, LastStation: "Victoria"}}, drones)
+	assert.Len(stations, 2)
+	assert.Equal(store.TrafficCounts{store.TrafficHeavy: 1}, stations[0].Counts)
+	assert.Equal(&testReport, stations[0].Latest)
+	assert.Nil(stations[1].Latest)
+}
+
+func TestServer_StreamEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a browser following the events
+	dashboard := newTestServer()
+	server := httptest.NewServer(dashboard)
+	defer server.Close()
+	response, err := http.Get(server.URL + "/events")
+	assert.NoError(err)
+	defer response.Body.Close()
+	assert.Equal("text/event-stream", response.Header.Get("Content-Type"))
+
+	// When the dispatcher produces events
+	assert.Eventually(func() bool {
+		dashboard.mu.Lock()
+		defer dashboard.mu.Unlock()
+		return len(dashboard.clients) == 1
+	}, time.Second, time.Millisecond)
+	for _, event := range testEvents {
+		dashboard.Handle(event)
+	}
+
+	// Then they should be streamed in order, named by kind
+	reader := bufio.NewReader(response.Body)
+	for _, expected := range testEvents {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			assert.NoError(err)
+			if line == "\n" {
+				break
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+
+		assert.Len(lines, 3)
+		assert.Equal("event: "+string(expected.Kind), lines[1])
+		var event agents.Event
+		assert.NoError(json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event))
+		assert.Equal(expected.Seq, event.Seq)
+	}
+}
+
+func TestServer_SlowClient(t *testing.T) {
+	// Given a browser that does not read its events
+	dashboard := newTestServer()
+	client := make(chan []byte, clientBuffer)
+	dashboard.clients[client] = struct{}{}
+
+	// When more events are produced than it can buffer
+	for i := 0; i <= clientBuffer; i++ {
+		dashboard.Handle(testEvents[1])
+	}
+
+	// Then it should be disconnected rather than block the dispatcher
+	assert.Empty(t, dashboard.clients)
+	_, open := <-client
+	assert.True(t, open, "the buffered events should still be readable")
+}
+
+func getJSON(t *testing.T, url string, value any) {
+	response, err := http.Get(url)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(value))
+}
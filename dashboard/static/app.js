@@ -0,0 +1,258 @@
+"use strict";
+
+// the map is fitted to the drones and the stations they reported at, padded by this many degrees of latitude
+const PADDING = 0.01;
+const VISIBILITY_KM = 0.35;
+const KM_PER_DEGREE = 111.195;
+const MAX_TRAIL = 2000;
+const MAX_FEED = 100;
+const COLORS = ["#1f77b4", "#9467bd", "#17becf", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#2ca02c"];
+const SVG = "http://www.w3.org/2000/svg";
+
+const state = {
+  stations: new Map(),
+  drones: new Map(),
+  trails: new Map(),
+  time: null,
+};
+
+function element(name, attributes, text) {
+  const node = document.createElementNS(SVG, name);
+  for (const [key, value] of Object.entries(attributes)) {
+    node.setAttribute(key, value);
+  }
+  if (text !== undefined) {
+    node.textContent = text;
+  }
+  return node;
+}
+
+function formatTime(time) {
+  return time ? new Date(time).toISOString().substring(11, 19) : "";
+}
+
+function droneColor(id) {
+  const ids = [...state.drones.keys()].sort((a, b) => a - b);
+  return COLORS[ids.indexOf(id) % COLORS.length];
+}
+
+// projection fits an equirectangular projection to the drones and the stations reported at, or to every station
+function projection(width, height) {
+  const points = [];
+  for (const trail of state.trails.values()) {
+    points.push(...trail);
+  }
+  for (const station of state.stations.values()) {
+    if (station.latest) {
+      points.push([station.latitude, station.longitude]);
+    }
+  }
+  if (points.length === 0) {
+    for (const station of state.stations.values()) {
+      points.push([station.latitude, station.longitude]);
+    }
+  }
+  if (points.length === 0) {
+    return null;
+  }
+
+  let north = -90, south = 90, west = 180, east = -180;
+  for (const [latitude, longitude] of points) {
+    north = Math.max(north, latitude);
+    south = Math.min(south, latitude);
+    west = Math.min(west, longitude);
+    east = Math.max(east, longitude);
+  }
+  const cos = Math.cos((north + south) / 2 * Math.PI / 180);
+  north += PADDING;
+  south -= PADDING;
+  west -= PADDING / cos;
+  east += PADDING / cos;
+
+  const scale = Math.min(width / ((east - west) * cos), height / (north - south));
+  const offsetX = (width - (east - west) * cos * scale) / 2;
+  const offsetY = (height - (north - south) * scale) / 2;
+  return {
+    scale,
+    project: (latitude, longitude) => [offsetX + (longitude - west) * cos * scale, offsetY + (north - latitude) * scale],
+  };
+}
+
+function renderMap() {
+  const svg = document.getElementById("map");
+  const [width, height] = [800, 600];
+  const fitted = projection(width, height);
+  const layers = ["visibility", "routes", "stations", "drones"].map((id) => document.getElementById(id));
+  layers.forEach((layer) => layer.replaceChildren());
+  if (!fitted) {
+    return;
+  }
+  const [visibility, routes, stations, drones] = layers;
+  const inside = ([x, y]) => x >= 0 && x <= width && y >= 0 && y <= height;
+
+  const shown = [...state.stations.values()].filter((station) => inside(fitted.project(station.latitude, station.longitude)));
+  const radius = VISIBILITY_KM / KM_PER_DEGREE * fitted.scale;
+  for (const station of shown) {
+    const [x, y] = fitted.project(station.latitude, station.longitude);
+    visibility.append(element("circle", { cx: x, cy: y, r: radius }));
+    const dot = element("circle", { cx: x, cy: y, r: 4, class: station.latest ? station.latest.condition : "" });
+    dot.append(element("title", {}, station.name));
+    stations.append(dot);
+    if (shown.length <= 60) {
+      stations.append(element("text", { x: x + 6, y: y - 4 }, station.name));
+    }
+  }
+
+  for (const [id, trail] of state.trails) {
+    const points = trail.map(([latitude, longitude]) => fitted.project(latitude, longitude).join(",")).join(" ");
+    routes.append(element("polyline", { points, stroke: droneColor(id) }));
+  }
+
+  for (const drone of state.drones.values()) {
+    if (!drone.location) {
+      continue;
+    }
+    const [x, y] = fitted.project(drone.location.Latitude, drone.location.Longitude);
+    drones.append(element("circle", { cx: x, cy: y, r: 6, fill: drone.status === "on" ? droneColor(drone.id) : "#fff" }));
+    drones.append(element("text", { x: x + 8, y: y + 4, fill: droneColor(drone.id) }, drone.id));
+  }
+}
+
+function renderTables() {
+  document.getElementById("clock").textContent = formatTime(state.time) || "--:--:--";
+
+  const droneRows = [...state.drones.values()].sort((a, b) => a.id - b.id).map((drone) => {
+    const position = drone.location ? `${drone.location.Latitude.toFixed(5)}, ${drone.location.Longitude.toFixed(5)}` : "";
+    return row([drone.id, drone.status || "", formatTime(drone.time), position, drone.reports, drone.last_station || ""]);
+  });
+  document.querySelector("#drone-table tbody").replaceChildren(...droneRows);
+
+  const stationRows = [...state.stations.values()]
+    .filter((station) => station.latest)
+    .sort((a, b) => new Date(b.latest.time) - new Date(a.latest.time))
+    .map((station) => {
+      const counts = station.counts || {};
+      const cells = [station.name, station.latest.condition, counts.HEAVY || 0, counts.MODERATE || 0, counts.LIGHT || 0, formatTime(station.latest.time)];
+      const tr = row(cells);
+      tr.children[1].className = station.latest.condition;
+      return tr;
+    });
+  document.querySelector("#station-table tbody").replaceChildren(...stationRows);
+}
+
+function row(cells) {
+  const tr = document.createElement("tr");
+  for (const cell of cells) {
+    const td = document.createElement("td");
+    td.textContent = cell;
+    tr.append(td);
+  }
+  return tr;
+}
+
+let scheduled = false;
+function render() {
+  if (scheduled) {
+    return;
+  }
+  scheduled = true;
+  requestAnimationFrame(() => {
+    scheduled = false;
+    renderMap();
+    renderTables();
+  });
+}
+
+function drone(id) {
+  if (!state.drones.has(id)) {
+    state.drones.set(id, { id, reports: 0 });
+  }
+  return state.drones.get(id);
+}
+
+function advance(time) {
+  if (!state.time || new Date(time) > new Date(state.time)) {
+    state.time = time;
+  }
+}
+
+const handlers = {
+  launch(event) {
+    drone(event.drone).status = "on";
+  },
+  restart(event) {
+    drone(event.drone).status = "on";
+  },
+  shutdown(event) {
+    drone(event.drone).status = "off";
+  },
+  move(event) {
+    drone(event.drone).location = event.location;
+    if (!state.trails.has(event.drone)) {
+      state.trails.set(event.drone, []);
+    }
+    const trail = state.trails.get(event.drone);
+    trail.push([event.location.Latitude, event.location.Longitude]);
+    if (trail.length > MAX_TRAIL) {
+      trail.shift();
+    }
+  },
+  report(event) {
+    const report = event.report;
+    const d = drone(event.drone);
+    d.reports++;
+    d.last_station = report.station;
+
+    const station = state.stations.get(report.station);
+    if (station) {
+      station.counts = station.counts || {};
+      station.counts[report.condition] = (station.counts[report.condition] || 0) + 1;
+      station.latest = report;
+    }
+
+    const item = document.createElement("li");
+    item.innerHTML = `<span></span> <span class="${report.condition}"></span>`;
+    item.children[0].textContent = `${formatTime(report.time)} ${report.drone} ${report.station}`;
+    item.children[1].textContent = report.condition;
+    const feed = document.getElementById("feed");
+    feed.prepend(item);
+    while (feed.children.length > MAX_FEED) {
+      feed.lastChild.remove();
+    }
+  },
+};
+
+async function start() {
+  for (const station of await (await fetch("api/stations")).json()) {
+    state.stations.set(station.name, station);
+  }
+  for (const status of await (await fetch("api/drones")).json()) {
+    state.drones.set(status.id, status);
+    if (status.location) {
+      state.trails.set(status.id, [[status.location.Latitude, status.location.Longitude]]);
+    }
+    advance(status.time);
+  }
+  render();
+
+  const source = new EventSource("events");
+  const connection = document.getElementById("connection");
+  source.onopen = () => {
+    connection.textContent = "live";
+    connection.className = "connected";
+  };
+  source.onerror = () => {
+    connection.textContent = "disconnected";
+    connection.className = "disconnected";
+  };
+  for (const [kind, handle] of Object.entries(handlers)) {
+    source.addEventListener(kind, (message) => {
+      const event = JSON.parse(message.data);
+      handle(event);
+      advance(event.time);
+      render();
+    });
+  }
+}
+
+start();
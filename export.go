@@ -0,0 +1,129 @@
+package main
+
+import (
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+)
+
+// exported defines what the export command writes, formats ignore what they cannot represent
+type exported struct {
+	routes   [][]store.Location
+	stations []store.Station
+	reports  []store.TrafficReport
+	// visibilityInKm draws the visibility circles of the stations in the formats that support it, if positive
+	visibilityInKm float64
+}
+
+// exporters write in the formats supported by the export command
+var exporters = map[string]func(w io.Writer, data exported) error{
+	"gpx": func(w io.Writer, data exported) error { return store.WriteGPX(w, data.routes...) },
+	"geojson": func(w io.Writer, data exported) error {
+		return store.WriteGeoJSON(w, data.routes, data.stations, data.reports)
+	},
+	"kml": func(w io.Writer, data exported) error {
+		return store.WriteKML(w, data.routes, data.stations, data.reports, store.KMLOptions{VisibilityRadiusInKm: data.visibilityInKm})
+	},
+	"svg": func(w io.Writer, data exported) error {
+		return store.WriteSVG(w, data.routes, data.stations, data.reports, store.SVGOptions{VisibilityRadiusInKm: data.visibilityInKm})
+	},
+}
+
+// export writes the routes of the given drones, or the locations they actually flew and the reports they made in a
+// journaled run, to a file
+func export(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: simulation export [-format gpx|geojson|kml|svg] [-o file] [-journal file] [drone ID...]")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "gpx", "format of the export")
+	output := flags.String("o", "", "write the export to this file instead of standard output")
+	journalPath := flags.String("journal", "", "export the locations flown in this journaled run instead of the routes")
+	clean := flags.Bool("clean", false, "clean the routes before exporting them")
+	visibility := flags.Bool("visibility", false, "draw the circles within which drones see the stations, in KML and SVG")
+	formats := addFormatFlags(flags)
+	flags.Parse(args)
+
+	fileFormat, err := formats.parse()
+	if err != nil {
+		logrus.Error(err)
+		return 2
+	}
+
+	write, ok := exporters[*format]
+	if !ok {
+		logrus.Errorf("Unknown export format %q", *format)
+		return 2
+	}
+
+	var data exported
+	if *visibility {
+		data.visibilityInKm = agents.MaxVisibilityInKm
+	}
+	if *journalPath != "" {
+		ids, err := parseDroneIDs(flags.Args(), nil)
+		if err != nil {
+			logrus.Error(err)
+			return 2
+		}
+		events, err := readJournal(*journalPath)
+		if err != nil {
+			logrus.Errorf("Could not read journal: %s", err)
+			return 1
+		}
+
+		recorder := agents.NewRecorder()
+		for _, event := range events {
+			if len(ids) == 0 || slices.Contains(ids, event.DroneID) {
+				recorder.Handle(event)
+			}
+		}
+		data.routes = recorder.Routes()
+		data.reports = recorder.Reports()
+	} else {
+		ids, err := parseDroneIDs(flags.Args(), drones)
+		if err != nil {
+			logrus.Error(err)
+			return 2
+		}
+
+		routeRepo := fileFormat.routes(store.Lenient)
+		if *clean {
+			routeRepo.Cleaner = store.DefaultRouteCleaner()
+		}
+		for _, id := range ids {
+			route, err := routeRepo.GetRoute(id)
+			if err != nil {
+				logrus.WithField("Drone", id).Errorf("Could not read route: %s", err)
+				return 1
+			}
+			data.routes = append(data.routes, route)
+		}
+	}
+
+	stations, err := fileFormat.stations(store.Lenient).GetStations()
+	if err != nil {
+		logrus.Errorf("Could not read stations: %s", err)
+		return 1
+	}
+	data.stations = stations
+
+	if *output == "" {
+		err = write(os.Stdout, data)
+	} else {
+		// the file is closed before success is told, as some file systems only fail a write when it is closed
+		err = createFile(*output, func(w io.Writer) error { return write(w, data) })
+	}
+	if err != nil {
+		logrus.Errorf("Could not write export: %s", err)
+		return 1
+	}
+	return 0
+}
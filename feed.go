@@ -0,0 +1,67 @@
+package main
+
+import (
+	"drone_simulation/store"
+	"flag"
+	"fmt"
+	"net"
+
+	"github.com/sirupsen/logrus"
+)
+
+// feed replays the routes of the given drones into the socket of a simulation run with -listen, as if they were
+// live positions
+func feed(args []string) int {
+	flags := flag.NewFlagSet("feed", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: simulation feed [-network udp|tcp] [-speed n] [-json] address [drone ID...]")
+		flags.PrintDefaults()
+	}
+	network := flags.String("network", "udp", "network of the address, udp or tcp")
+	speed := flags.Float64("speed", 1, "number of simulated seconds per real second, as fast as possible if 0")
+	asJSON := flags.Bool("json", false, "send each position as a JSON object, its fields named by -json-fields, instead of a CSV line")
+	formats := addFormatFlags(flags)
+	flags.Parse(args)
+
+	format, err := formats.parse()
+	if err != nil {
+		logrus.Error(err)
+		return 2
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+	ids, err := parseDroneIDs(flags.Args()[1:], drones)
+	if err != nil {
+		logrus.Error(err)
+		return 2
+	}
+
+	var routes [][]store.Location
+	routeRepo := format.routes(store.Lenient)
+	for _, id := range ids {
+		route, err := routeRepo.GetRoute(id)
+		if err != nil {
+			logrus.WithField("Drone", id).Errorf("Could not read route: %s", err)
+			return 1
+		}
+		routes = append(routes, route)
+	}
+
+	conn, err := net.Dial(*network, flags.Arg(0))
+	if err != nil {
+		logrus.Errorf("Could not connect: %s", err)
+		return 1
+	}
+	defer conn.Close()
+
+	// the positions are written in the format the simulation listening with the same flags parses
+	feedConfig := store.FeedConfig{Speed: *speed, TimeFormat: format.timeFormat, JSON: *asJSON, JSONFields: format.jsonFields}
+	if err := store.FeedRoutes(conn, routes, feedConfig); err != nil {
+		logrus.Errorf("Could not feed routes: %s", err)
+		return 1
+	}
+	return 0
+}
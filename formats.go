@@ -0,0 +1,59 @@
+package main
+
+import (
+	"drone_simulation/store"
+	"flag"
+	"fmt"
+)
+
+// formatFlags holds the flags describing the formats of the data files, registered by every command reading them
+type formatFlags struct {
+	timeLayouts *string
+	timeZone    *string
+	epoch       *string
+	jsonFields  *string
+}
+
+// dataFormat defines how the data files are parsed, as given by formatFlags
+type dataFormat struct {
+	timeFormat store.TimeFormat
+	jsonFields store.JSONFields
+}
+
+// addFormatFlags registers the flags describing the formats of the data files
+func addFormatFlags(flags *flag.FlagSet) formatFlags {
+	return formatFlags{
+		timeLayouts: flags.String("time-layouts", "", "comma-separated Go layouts of the routes' timestamps, e.g. \"02/01/2006 15:04\""),
+		timeZone:    flags.String("time-zone", "", "IANA time zone of the routes' timestamps without an offset, e.g. Europe/London, UTC by default"),
+		epoch:       flags.String("epoch", "", "parse the routes' timestamps as Unix time in seconds (s) or milliseconds (ms)"),
+		jsonFields:  flags.String("json-fields", "", "names of the fields of JSON routes and stations, e.g. \"latitude=lat|y,longitude=lng|x\""),
+	}
+}
+
+// parse returns the format given by the flags, once they are parsed
+func (f formatFlags) parse() (dataFormat, error) {
+	timeFormat, err := store.NewTimeFormat(*f.timeLayouts, *f.timeZone, *f.epoch)
+	if err != nil {
+		return dataFormat{}, fmt.Errorf("invalid time format: %w", err)
+	}
+	jsonFields, err := store.ParseJSONFields(*f.jsonFields)
+	if err != nil {
+		return dataFormat{}, fmt.Errorf("invalid JSON fields: %w", err)
+	}
+	return dataFormat{timeFormat: timeFormat, jsonFields: jsonFields}, nil
+}
+
+// routes returns a repository of the route files in this format
+func (f dataFormat) routes(mode store.ParseMode) store.DefaultRouteRepository {
+	return store.DefaultRouteRepository{Mode: mode, TimeFormat: f.timeFormat, JSONFields: f.jsonFields}
+}
+
+// stations returns a repository of the station file in this format
+func (f dataFormat) stations(mode store.ParseMode) store.DefaultStationRepository {
+	return store.DefaultStationRepository{Mode: mode, JSONFields: f.jsonFields}
+}
+
+// segments returns a repository of the segments file in this format
+func (f dataFormat) segments(mode store.ParseMode) store.DefaultSegmentRepository {
+	return store.DefaultSegmentRepository{Mode: mode, JSONFields: f.jsonFields}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/sirupsen/logrus"
+)
+
+// heatmap writes the heatmap of the traffic reported in a journaled run as a CSV matrix and a PNG image, and
+// optionally a frame of both per window of time
+func heatmap(args []string) int {
+	flags := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: simulation heatmap [-cell metres] [-scale pixels] [-window duration] [-o prefix] <journal>")
+		flags.PrintDefaults()
+	}
+	cellSize := flags.Float64("cell", 250, "side of a cell of the grid, in metres")
+	scale := flags.Int("scale", 4, "side of a cell in the images, in pixels")
+	window := flags.Duration("window", 0, "also write a frame per window of simulated time, e.g. 5m, for an animation")
+	output := flags.String("o", "heatmap", "prefix of the files written, <prefix>.csv and <prefix>.png, and <prefix>-001.csv... for the frames")
+	formats := addFormatFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	format, err := formats.parse()
+	if err != nil {
+		logrus.Error(err)
+		return 2
+	}
+
+	events, err := readJournal(flags.Arg(0))
+	if err != nil {
+		logrus.Errorf("Could not read journal: %s", err)
+		return 1
+	}
+	recorder := agents.NewRecorder()
+	for _, event := range events {
+		recorder.Handle(event)
+	}
+	reports := recorder.Reports()
+
+	stations, err := format.stations(store.Lenient).GetStations()
+	if err != nil {
+		logrus.Errorf("Could not read stations: %s", err)
+		return 1
+	}
+	total, err := store.NewHeatmap(stations, *cellSize)
+	if err != nil {
+		logrus.Errorf("Could not draw heatmap: %s", err)
+		return 1
+	}
+	for _, report := range reports {
+		if !total.Add(report) {
+			logrus.WithField("Station", report.Station).Warn("Report outside of the heatmap")
+		}
+	}
+	if err := writeHeatmap(*output, total, store.HeatmapImageOptions{PixelsPerCell: *scale}); err != nil {
+		logrus.Errorf("Could not write heatmap: %s", err)
+		return 1
+	}
+
+	if *window > 0 {
+		frames, err := store.HeatmapSeries(stations, reports, *cellSize, *window)
+		if err != nil {
+			logrus.Errorf("Could not draw heatmap frames: %s", err)
+			return 1
+		}
+		// the frames share the scale of the hottest one, so that colors compare from frame to frame
+		options := store.HeatmapImageOptions{PixelsPerCell: *scale}
+		for frame := range frames {
+			options.MaxSeverity = math.Max(options.MaxSeverity, frame.MaxSeverity())
+		}
+		i := 0
+		for frame := range frames {
+			i++
+			prefix := fmt.Sprintf("%s-%03d", *output, i)
+			if err := writeHeatmap(prefix, frame.Heatmap, options); err != nil {
+				logrus.Errorf("Could not write heatmap frame: %s", err)
+				return 1
+			}
+			logrus.WithField("From", frame.Start.Format("15:04:05")).WithField("To", frame.End.Format("15:04:05")).Info(prefix)
+		}
+	}
+	return 0
+}
+
+// writeHeatmap writes a heatmap to <prefix>.csv and <prefix>.png
+func writeHeatmap(prefix string, heatmap *store.Heatmap, options store.HeatmapImageOptions) error {
+	if err := createFile(prefix+".csv", heatmap.WriteCSV); err != nil {
+		return err
+	}
+	return createFile(prefix+".png", func(w io.Writer) error { return heatmap.WritePNG(w, options) })
+}
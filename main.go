@@ -2,27 +2,354 @@ package main
 
 import (
 	"drone_simulation/agents"
-	"sync"
+	"drone_simulation/dashboard"
+	"drone_simulation/metrics"
+	"drone_simulation/store"
+	"drone_simulation/tui"
+	"flag"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
 	"time"
-)
+	_ "time/tzdata"
 
-const (
-	shutDownTime = "2011-03-22T08:10:00Z"
-	timeLayout   = time.RFC3339
+	"github.com/sirupsen/logrus"
 )
 
+const shutDownTime = "2011-03-22 08:10:00"
+
 var drones = []int{5937, 6043}
 
+// commands are the subcommands of the binary, the simulation is run when none is given
+var commands = map[string]func(args []string) int{
+	"replay":   replay,
+	"diff":     diff,
+	"export":   export,
+	"feed":     feed,
+	"heatmap":  heatmap,
+	"network":  network,
+	"validate": validate,
+}
+
 func main() {
-	shutDownTime, _ := time.Parse(timeLayout, shutDownTime)
-	dispatcher := agents.NewDispatcher(&shutDownTime)
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("simulation", flag.ExitOnError)
+	synchronized := flags.Bool("sync", false, "release every waypoint at its own timestamp on a clock shared by all drones")
+	speed := flags.Float64("speed", 1, "number of simulated seconds per real second")
+	shutDownAt := flags.String("shutdown", shutDownTime, "simulated time at which the drones are shut down, in the routes' time zone")
+	pauseAt := flags.String("pause-at", "", "pause the simulation once the simulated time reaches this time, in the routes' time zone")
+	interactive := flags.Bool("control", false, "read pause, resume, step and list commands from standard input")
+	seed := flags.Uint64("seed", 0, "seed of every random decision, a random seed is picked if 0")
+	journalPath := flags.String("journal", "", "write every event of the simulation to this file")
+	geoJSONPath := flags.String("geojson", "", "write every traffic report to this file as a GeoJSON Point feature in a GeoJSON text sequence (RFC 8142), e.g. reports.geojsons")
+	fusionStrategy := flags.String("fusion", "", "fuse the reports of several drones at a station by this strategy: latest, majority or weighted")
+	fusionWindow := flags.Duration("fusion-window", time.Minute, "how close in simulated time the reports of -fusion must be")
+	trafficPath := flags.String("traffic-csv", "", "write the traffic reported at every station per -traffic-window to this CSV file at the end of the run")
+	trafficWindow := flags.Duration("traffic-window", 5*time.Minute, "length of the windows of -traffic-csv, a single window per station if 0")
+	checkpointPath := flags.String("checkpoint", "", "periodically save the state of the simulation to this file")
+	checkpointInterval := flags.Duration("checkpoint-every", 10*time.Second, "real time between two checkpoints")
+	resume := flags.Bool("resume", false, "resume the simulation from the -checkpoint file")
+	clean := flags.Bool("clean", false, "sort routes and drop duplicate and impossible locations before flying them, reading whole routes instead of streaming them")
+	strict := flags.Bool("strict", false, "fail on the first line of a data file that cannot be parsed, instead of skipping it")
+	formats := addFormatFlags(flags)
+	listen := flags.String("listen", "", "fly the positions received on this address, e.g. localhost:7000, instead of the route files")
+	listenNetwork := flags.String("listen-network", "udp", "network of -listen, udp or tcp")
+	follow := flags.Bool("follow", false, "keep flying the lines appended to the route files as they are written, like tail -f")
+	showTUI := flags.Bool("tui", false, "show the drones and the traffic they report full-screen in the terminal, with keys to pause, resume and change the speed")
+	dashboardAddress := flags.String("dashboard", "", "serve a live map of the simulation on this address, e.g. localhost:8080")
+	metricsAddress := flags.String("metrics", "", "serve metrics of the simulation in the Prometheus text format at /metrics on this address, e.g. localhost:9090")
+	idleTimeout := flags.Duration("idle-timeout", time.Minute, "shut a drone down when no position arrived for it for this long, with -listen or -follow")
+	flags.Parse(args)
+	if *showTUI && *interactive {
+		logrus.Error("-tui and -control both read the keyboard, use only one of them")
+		return 1
+	}
+
+	format, err := formats.parse()
+	if err != nil {
+		logrus.Error(err)
+		return 1
+	}
+	timeFormat := format.timeFormat
+	shutDownTime, err := parseTime(timeFormat, *shutDownAt)
+	if err != nil {
+		logrus.Errorf("Invalid shutdown time: %s", err)
+		return 1
+	}
+
+	parseMode := store.Lenient
+	if *strict {
+		parseMode = store.Strict
+	}
+	fileRepo := format.routes(parseMode)
+	fileRepo.Follow = *follow
+	fileRepo.IdleTimeout = *idleTimeout
+	if *follow {
+		for _, id := range drones {
+			if err := store.CheckFollowable(id); err != nil {
+				logrus.WithField("Drone", id).Errorf("Cannot follow route: %s", err)
+				return 1
+			}
+		}
+	}
+	if *clean {
+		fileRepo.Cleaner = store.DefaultRouteCleaner()
+	}
+	var routeRepo store.RouteRepository = fileRepo
+	if *listen != "" {
+		networkRepo, err := store.NewNetworkRouteRepository(store.NetworkRouteConfig{
+			Network:     *listenNetwork,
+			Address:     *listen,
+			TimeFormat:  timeFormat,
+			JSONFields:  format.jsonFields,
+			IdleTimeout: *idleTimeout,
+			Drones:      drones,
+		})
+		if err != nil {
+			logrus.Errorf("Could not listen for positions: %s", err)
+			return 1
+		}
+		defer networkRepo.Close()
+		routeRepo = networkRepo
+	}
+
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+	logrus.WithField("Seed", *seed).Info("Seeded")
+
+	var resumed *agents.Checkpoint
+	if *resume {
+		checkpoint, err := agents.LoadCheckpoint(*checkpointPath)
+		if err != nil {
+			logrus.Errorf("Could not load checkpoint: %s", err)
+			return 1
+		}
+		resumed = &checkpoint
+		logrus.WithField("Time", checkpoint.Time.Format(time.TimeOnly)).Info("Resuming from checkpoint")
+	}
+
+	clockConfig := agents.ClockConfig{Synchronized: *synchronized, Speed: *speed}
+	if resumed != nil {
+		clockConfig.Start = resumed.Time
+	} else if *synchronized && !store.IsLive(routeRepo) {
+		clockConfig.Start = simulationStart(routeRepo, drones)
+	}
+	clock := agents.NewClock(clockConfig)
+
+	var sinks []agents.EventSink
+	if *journalPath != "" {
+		file, err := openJournal(*journalPath, resumed)
+		if err != nil {
+			logrus.Errorf("Could not create journal: %s", err)
+			return 1
+		}
+		defer file.Close()
+
+		sinks = append(sinks, agents.NewJournal(file))
+	}
+	if *geoJSONPath != "" {
+		file, err := os.Create(*geoJSONPath)
+		if err != nil {
+			logrus.Errorf("Could not create GeoJSON reports: %s", err)
+			return 1
+		}
+		defer file.Close()
+
+		sinks = append(sinks, agents.NewGeoJSONSink(file))
+	}
+	if *dashboardAddress != "" {
+		stations, err := format.stations(parseMode).GetStations()
+		if err != nil {
+			logrus.Errorf("Could not read stations: %s", err)
+			return 1
+		}
+		server := dashboard.NewServer(stations)
+		listener, err := net.Listen("tcp", *dashboardAddress)
+		if err != nil {
+			logrus.Errorf("Could not serve dashboard: %s", err)
+			return 1
+		}
+		defer listener.Close()
+		go http.Serve(listener, server)
+		logrus.WithField("URL", "http://"+listener.Addr().String()).Info("Serving dashboard")
+
+		sinks = append(sinks, server)
+	}
+	if *metricsAddress != "" {
+		// the simulated time of an unsynchronized clock is not shared by the drones, so nothing lags behind it
+		var collectorConfig metrics.CollectorConfig
+		if store.IsLive(routeRepo) {
+			collectorConfig.Now = time.Now
+		} else if *synchronized {
+			collectorConfig.Now = clock.Now
+		}
+		collectorConfig.Resumed = resumed
+		collector := metrics.NewCollector(collectorConfig)
+		listener, err := net.Listen("tcp", *metricsAddress)
+		if err != nil {
+			logrus.Errorf("Could not serve metrics: %s", err)
+			return 1
+		}
+		defer listener.Close()
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", collector)
+		go http.Serve(listener, mux)
+		logrus.WithField("URL", "http://"+listener.Addr().String()+"/metrics").Info("Serving metrics")
+
+		sinks = append(sinks, collector)
+	}
+	var view *tui.View
+	if *showTUI {
+		view = tui.NewView(tui.ViewConfig{})
+		sinks = append(sinks, view)
+	}
+	aggregator := store.NewTrafficAggregator()
+	if *trafficPath != "" {
+		sinks = append(sinks, agents.ReportSink(aggregator.Add))
+	}
+
+	dispatcherConfig := agents.DispatcherConfig{ShutDownTime: &shutDownTime, Sinks: sinks}
+	if *fusionStrategy != "" {
+		strategy, ok := agents.FusionStrategies[*fusionStrategy]
+		if !ok {
+			logrus.Errorf("Unknown fusion strategy %q", *fusionStrategy)
+			return 1
+		}
+		fusionConfig := &agents.FusionConfig{Strategy: strategy, Window: *fusionWindow}
+		// unsynchronized drones each keep their own time, so their reports arrive out of time order and what is
+		// fused would depend on how the drones are scheduled, whereas live positions arrive in time order
+		if !*synchronized && !store.IsLive(routeRepo) {
+			logrus.Error("Fusing reports needs -sync, for the drones to report in the order of simulated time")
+			return 1
+		}
+		dispatcherConfig.Fusion = fusionConfig
+	}
+	if resumed != nil {
+		dispatcherConfig.LastSeq = resumed.Seq
+	}
+	dispatcherConfig.Clock = clock
+	dispatcher := agents.NewDispatcherWithConfig(dispatcherConfig)
+
+	if *pauseAt != "" {
+		t, err := parseTime(timeFormat, *pauseAt)
+		if err != nil {
+			logrus.Errorf("Invalid pause time %q: %s", *pauseAt, err)
+			return 1
+		}
+		dispatcher.PauseAt(t)
+	}
+	if *interactive {
+		go control(os.Stdin, os.Stdout, dispatcher)
+	}
+
+	newDrone := func(id int) agents.Drone {
+		return agents.NewDrone(id, agents.DroneConfig{
+			StationRepo:  format.stations(parseMode),
+			RandomSource: rand.NewPCG(*seed, uint64(id)),
+		})
+	}
+	if resumed != nil {
+		for _, checkpoint := range resumed.Drones {
+			if err := dispatcher.ResumeDrone(newDrone(checkpoint.ID), routeRepo, checkpoint); err != nil {
+				logrus.WithField("Drone", checkpoint.ID).Error(err)
+			}
+		}
+	} else {
+		for _, id := range drones {
+			if err := dispatcher.AddDrone(newDrone(id), routeRepo, time.Time{}); err != nil {
+				logrus.WithField("Drone", id).Error(err)
+			}
+		}
+	}
+
+	if *checkpointPath != "" {
+		done := make(chan struct{})
+		defer close(done)
+		go saveCheckpoints(dispatcher, *checkpointPath, *checkpointInterval, done)
+	}
+
+	stopView := func() {}
+	if view != nil {
+		stopView = runView(view, dispatcher)
+	}
+
+	dispatcher.Wait()
+	stopView()
 
-	var wg sync.WaitGroup
-	for _, id := range drones {
-		drone := agents.NewDroneWithDefaults(id)
+	if *trafficPath != "" {
+		if err := writeTraffic(*trafficPath, aggregator, *trafficWindow); err != nil {
+			logrus.Errorf("Could not write traffic: %s", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// runView runs the terminal view until the returned function is called, the terminal restored once it returns
+func runView(view *tui.View, dispatcher agents.Dispatcher) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := view.Run(dispatcher, done); err != nil {
+			logrus.Errorf("Could not show terminal view: %s", err)
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// writeTraffic writes the rollups of the traffic reported at every station to a CSV file
+func writeTraffic(path string, aggregator *store.TrafficAggregator, window time.Duration) error {
+	return createFile(path, func(w io.Writer) error { return aggregator.WriteCSV(w, window) })
+}
+
+// createFile creates a file and writes it with write
+func createFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// parseTime parses a time given on the command line with the routes' time format, or as text in their time zone
+func parseTime(format store.TimeFormat, value string) (time.Time, error) {
+	t, err := format.Parse(value)
+	if err != nil {
+		t, err = store.TimeFormat{Location: format.Location}.Parse(value)
+	}
+	return t, err
+}
 
-		go dispatcher.Fly(drone, &wg)
-		wg.Add(1)
+// simulationStart returns the earliest first waypoint of the given drones' routes
+func simulationStart(routeRepo store.RouteRepository, ids []int) time.Time {
+	var start time.Time
+	for _, id := range ids {
+		for location, err := range store.StreamRoute(routeRepo, id) {
+			if err == nil && (start.IsZero() || location.Time.Before(start)) {
+				start = location.Time
+			}
+			break
+		}
 	}
-	wg.Wait()
+	return start
 }
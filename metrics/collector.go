@@ -0,0 +1,232 @@
+// Package metrics serves operational metrics of a simulation in the Prometheus text format, counted from the events
+// of the dispatcher
+package metrics
+
+import (
+	"bufio"
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/umahmood/haversine"
+)
+
+// ContentType is the media type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// LatencyBuckets are the upper bounds in seconds of the buckets of the report latency histogram
+var LatencyBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// CollectorConfig holds configuration for creating a collector
+type CollectorConfig struct {
+	// Now returns the simulated time the reports and events are measured against, e.g. the dispatcher's clock when
+	// synchronized or time.Now for live positions. The report latency and time lag are not measured if not set.
+	Now func() time.Time
+	// Resumed is the checkpoint the simulation resumed from, if any, whose drones in flight are counted as flying
+	// with the reports and charge they were restored with, as no launch precedes their next events
+	Resumed *agents.Checkpoint
+}
+
+// droneMetrics holds the counters and gauges of a drone
+type droneMetrics struct {
+	moves       int
+	reports     int
+	restarts    int
+	outOfMemory int
+	flying      bool
+	// memory is the number of reports made since the drone was last started
+	memory   int
+	speedKph float64
+	battery  float64
+	// last is the waypoint of the last move, the speed being measured from it to the next
+	last *store.Location
+}
+
+// Collector is an EventSink counting what every drone does, served as an http.Handler in the Prometheus text format
+type Collector struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	drones  map[int]*droneMetrics
+	fusions int
+	// latest is the simulated time of the latest event
+	latest time.Time
+	// latency counts the report latencies per bucket of LatencyBuckets, the last bucket being +Inf
+	latency    []int
+	latencySum float64
+}
+
+// NewCollector returns a new collector
+func NewCollector(config CollectorConfig) *Collector {
+	c := &Collector{
+		now:     config.Now,
+		drones:  map[int]*droneMetrics{},
+		latency: make([]int, len(LatencyBuckets)+1),
+	}
+	if config.Resumed != nil {
+		for _, checkpoint := range config.Resumed.Drones {
+			if checkpoint.RouteIndex < 0 {
+				continue
+			}
+			location := checkpoint.Location
+			c.drones[checkpoint.ID] = &droneMetrics{
+				flying:  true,
+				memory:  len(checkpoint.State.Reports),
+				battery: checkpoint.State.Battery,
+				last:    &location,
+			}
+		}
+	}
+	return c
+}
+
+// Handle counts an event
+func (c *Collector) Handle(event agents.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if event.Time.After(c.latest) {
+		c.latest = event.Time
+	}
+	if event.Kind == agents.EventFusion {
+		c.fusions++
+		return
+	}
+
+	drone, ok := c.drones[event.DroneID]
+	if !ok {
+		drone = &droneMetrics{}
+		c.drones[event.DroneID] = drone
+	}
+	switch event.Kind {
+	case agents.EventLaunch:
+		drone.flying = true
+		drone.memory = 0
+		drone.last = nil
+	case agents.EventRestart:
+		drone.restarts++
+		if event.Reason == agents.RestartOutOfMemory {
+			drone.outOfMemory++
+		}
+		drone.memory = 0
+	case agents.EventShutDown:
+		drone.flying = false
+		drone.speedKph = 0
+	case agents.EventMove:
+		drone.moves++
+		drone.speedKph = 0
+		if previous := drone.last; previous != nil && event.Location.Time.After(previous.Time) {
+			_, km := haversine.Distance(
+				haversine.Coord{Lat: previous.Latitude, Lon: previous.Longitude},
+				haversine.Coord{Lat: event.Location.Latitude, Lon: event.Location.Longitude},
+			)
+			drone.speedKph = km / event.Location.Time.Sub(previous.Time).Hours()
+		}
+		drone.last = event.Location
+		drone.battery = event.Battery
+	case agents.EventReport:
+		drone.reports++
+		drone.memory++
+		if c.now != nil {
+			c.observeLatency(c.now().Sub(event.Report.Time))
+		}
+	}
+}
+
+// observeLatency adds a report latency to the histogram, a report made ahead of the clock counting as no latency
+func (c *Collector) observeLatency(latency time.Duration) {
+	seconds := math.Max(latency.Seconds(), 0)
+	c.latencySum += seconds
+	bucket := sort.SearchFloat64s(LatencyBuckets, seconds)
+	c.latency[bucket]++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if err := c.WriteMetrics(w); err != nil {
+		logrus.Errorf("Could not write metrics: %s", err)
+	}
+}
+
+// WriteMetrics writes the metrics in the Prometheus text format
+func (c *Collector) WriteMetrics(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ids := make([]int, 0, len(c.drones))
+	for id := range c.drones {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	out := bufio.NewWriter(w)
+	perDrone := func(name, kind, help string, value func(drone *droneMetrics) float64) {
+		writeHeader(out, name, kind, help)
+		for _, id := range ids {
+			fmt.Fprintf(out, "%s{drone=\"%d\"} %s\n", name, id, formatValue(value(c.drones[id])))
+		}
+	}
+	perDrone("drone_simulation_moves_total", "counter", "Waypoints reached by the drone.",
+		func(drone *droneMetrics) float64 { return float64(drone.moves) })
+	perDrone("drone_simulation_reports_total", "counter", "Traffic reports made by the drone.",
+		func(drone *droneMetrics) float64 { return float64(drone.reports) })
+	perDrone("drone_simulation_restarts_total", "counter", "Restarts of the drone.",
+		func(drone *droneMetrics) float64 { return float64(drone.restarts) })
+	perDrone("drone_simulation_out_of_memory_total", "counter", "Restarts of the drone because it ran out of memory.",
+		func(drone *droneMetrics) float64 { return float64(drone.outOfMemory) })
+	perDrone("drone_simulation_speed_kph", "gauge", "Speed of the drone between its last two waypoints, in km/h.",
+		func(drone *droneMetrics) float64 { return drone.speedKph })
+	perDrone("drone_simulation_battery_ratio", "gauge", "Charge left to the battery of the drone after its last move, from 0 when flat to 1 when full.",
+		func(drone *droneMetrics) float64 { return drone.battery })
+	perDrone("drone_simulation_buffer_fill_ratio", "gauge",
+		fmt.Sprintf("Reports held in the memory of the drone, as a fraction of the %d it can hold.", agents.MaxMemory),
+		func(drone *droneMetrics) float64 { return float64(drone.memory) / float64(agents.MaxMemory) })
+
+	flying := 0
+	for _, drone := range c.drones {
+		if drone.flying {
+			flying++
+		}
+	}
+	writeHeader(out, "drone_simulation_fleet_size", "gauge", "Drones flying.")
+	fmt.Fprintf(out, "drone_simulation_fleet_size %d\n", flying)
+	writeHeader(out, "drone_simulation_fusions_total", "counter", "Fusions of the reports of several drones at a station.")
+	fmt.Fprintf(out, "drone_simulation_fusions_total %d\n", c.fusions)
+
+	if c.now != nil {
+		writeHeader(out, "drone_simulation_report_latency_seconds", "histogram",
+			"Simulated time between a traffic report and its publication.")
+		count := 0
+		for i, bound := range LatencyBuckets {
+			count += c.latency[i]
+			fmt.Fprintf(out, "drone_simulation_report_latency_seconds_bucket{le=\"%s\"} %d\n", formatValue(bound), count)
+		}
+		count += c.latency[len(LatencyBuckets)]
+		fmt.Fprintf(out, "drone_simulation_report_latency_seconds_bucket{le=\"+Inf\"} %d\n", count)
+		fmt.Fprintf(out, "drone_simulation_report_latency_seconds_sum %s\n", formatValue(c.latencySum))
+		fmt.Fprintf(out, "drone_simulation_report_latency_seconds_count %d\n", count)
+
+		if !c.latest.IsZero() {
+			writeHeader(out, "drone_simulation_time_lag_seconds", "gauge",
+				"Simulated time between the clock and the latest event, e.g. while the drones fly towards their next waypoint.")
+			fmt.Fprintf(out, "drone_simulation_time_lag_seconds %s\n", formatValue(c.now().Sub(c.latest).Seconds()))
+		}
+	}
+	return out.Flush()
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testStart     = time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	testLocation  = store.Location{DroneID: 5937, Latitude: 51.4965, Longitude: -0.1449, Time: testStart}
+	testLocation2 = store.Location{DroneID: 5937, Latitude: 51.4974, Longitude: -0.1449, Time: testStart.Add(10 * time.Second)}
+)
+
+// testEvents returns the events of a drone that runs out of memory and restarts, and of one that shuts down
+func testEvents() []agents.Event {
+	events := []agents.Event{
+		{Kind: agents.EventLaunch, DroneID: 5937, Time: testStart},
+		{Kind: agents.EventMove, DroneID: 5937, Time: testStart, Location: &testLocation, Battery: 1},
+	}
+	for i := 0; i < agents.MaxMemory; i++ {
+		report := store.TrafficReport{DroneID: 5937, Station: "Victoria", Time: testStart, Condition: store.TrafficHeavy}
+		events = append(events, agents.Event{Kind: agents.EventReport, DroneID: 5937, Time: testStart, Report: &report})
+	}
+	report := store.TrafficReport{DroneID: 5937, Station: "Victoria", Time: testLocation2.Time, Condition: store.TrafficLight}
+	events = append(events,
+		agents.Event{Kind: agents.EventRestart, DroneID: 5937, Time: testStart, Reason: agents.RestartOutOfMemory},
+		agents.Event{Kind: agents.EventMove, DroneID: 5937, Time: testLocation2.Time, Location: &testLocation2, Battery: 0.95},
+		agents.Event{Kind: agents.EventReport, DroneID: 5937, Time: testLocation2.Time, Report: &report},
+		agents.Event{Kind: agents.EventLaunch, DroneID: 6043, Time: testStart},
+		agents.Event{Kind: agents.EventShutDown, DroneID: 6043, Time: testStart, Reason: "empty route"},
+		agents.Event{Kind: agents.EventFusion, Time: testLocation2.Time, Report: &report, Fusion: &agents.Fusion{Strategy: "latest"}},
+	)
+	for i := range events {
+		events[i].Seq = i + 1
+	}
+	return events
+}
+
+func TestCollector_WriteMetrics(t *testing.T) {
+	now := func() time.Time { return testStart.Add(15 * time.Second) }
+
+	testCases := []struct {
+		name            string
+		now             func() time.Time
+		expectedLines   []string
+		unexpectedNames []string
+	}{
+		{
+			name: "WriteMetrics() should write the counters and gauges of every drone and of the fleet",
+			now:  now,
+			expectedLines: []string{
+				"# TYPE drone_simulation_moves_total counter",
+				`drone_simulation_moves_total{drone="5937"} 2`,
+				`drone_simulation_moves_total{drone="6043"} 0`,
+				`drone_simulation_reports_total{drone="5937"} 11`,
+				`drone_simulation_restarts_total{drone="5937"} 1`,
+				`drone_simulation_out_of_memory_total{drone="5937"} 1`,
+				`drone_simulation_out_of_memory_total{drone="6043"} 0`,
+				`drone_simulation_battery_ratio{drone="5937"} 0.95`,
+				`drone_simulation_battery_ratio{drone="6043"} 0`,
+				`drone_simulation_buffer_fill_ratio{drone="5937"} 0.1`,
+				`drone_simulation_speed_kph{drone="6043"} 0`,
+				"drone_simulation_fleet_size 1",
+				"drone_simulation_fusions_total 1",
+				"# TYPE drone_simulation_report_latency_seconds histogram",
+				`drone_simulation_report_latency_seconds_bucket{le="1"} 0`,
+				`drone_simulation_report_latency_seconds_bucket{le="5"} 1`,
+				`drone_simulation_report_latency_seconds_bucket{le="30"} 11`,
+				`drone_simulation_report_latency_seconds_bucket{le="+Inf"} 11`,
+				"drone_simulation_report_latency_seconds_sum 155",
+				"drone_simulation_report_latency_seconds_count 11",
+				"drone_simulation_time_lag_seconds 5",
+			},
+		},
+		{
+			name: "WriteMetrics() should not measure latency and lag without a clock",
+			expectedLines: []string{
+				`drone_simulation_reports_total{drone="5937"} 11`,
+			},
+			unexpectedNames: []string{"drone_simulation_report_latency_seconds", "drone_simulation_time_lag_seconds"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given a collector of the events of two drones
+			collector := NewCollector(CollectorConfig{Now: testCase.now})
+			for _, event := range testEvents() {
+				collector.Handle(event)
+			}
+
+			// When
+			var out strings.Builder
+			err := collector.WriteMetrics(&out)
+
+			// Then
+			assert.NoError(t, err)
+			lines := strings.Split(out.String(), "\n")
+			for _, expected := range testCase.expectedLines {
+				assert.Contains(t, lines, expected)
+			}
+			for _, unexpected := range testCase.unexpectedNames {
+				assert.NotContains(t, out.String(), unexpected)
+			}
+		})
+	}
+}
+
+func TestCollector_Resumed(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a collector of a simulation resumed with a drone in flight holding four reports, and one not lifted off
+	reports := make([]store.TrafficReport, 4)
+	collector := NewCollector(CollectorConfig{Resumed: &agents.Checkpoint{Drones: []agents.DroneCheckpoint{
+		{ID: 5937, RouteIndex: 3, Location: testLocation, State: agents.DroneState{Reports: reports, Battery: 0.5}},
+		{ID: 6043, RouteIndex: -1},
+	}}})
+
+	// Then the drone in flight should be counted with the reports it holds
+	var out strings.Builder
+	assert.NoError(collector.WriteMetrics(&out))
+	lines := strings.Split(out.String(), "\n")
+	assert.Contains(lines, `drone_simulation_buffer_fill_ratio{drone="5937"} 0.4`)
+	assert.Contains(lines, `drone_simulation_battery_ratio{drone="5937"} 0.5`)
+	assert.Contains(lines, "drone_simulation_fleet_size 1")
+
+	// When it restarts with a flat battery
+	collector.Handle(agents.Event{Kind: agents.EventRestart, DroneID: 5937, Time: testStart, Reason: agents.RestartBatteryFlat})
+
+	// Then the restart should not be counted as out of memory
+	out.Reset()
+	assert.NoError(collector.WriteMetrics(&out))
+	lines = strings.Split(out.String(), "\n")
+	assert.Contains(lines, `drone_simulation_restarts_total{drone="5937"} 1`)
+	assert.Contains(lines, `drone_simulation_out_of_memory_total{drone="5937"} 0`)
+	assert.Contains(lines, `drone_simulation_buffer_fill_ratio{drone="5937"} 0`)
+}
+
+func TestCollector_Speed(t *testing.T) {
+	// Given a collector of a drone flying a hundred metres in ten seconds
+	collector := NewCollector(CollectorConfig{})
+	for _, event := range testEvents()[:14] {
+		collector.Handle(event)
+	}
+
+	// When
+	var out strings.Builder
+	assert.NoError(t, collector.WriteMetrics(&out))
+
+	// Then its speed should be 36 km/h
+	match := regexp.MustCompile(`drone_simulation_speed_kph\{drone="5937"\} (\S+)`).FindStringSubmatch(out.String())
+	assert.Len(t, match, 2)
+	speed, err := strconv.ParseFloat(match[1], 64)
+	assert.NoError(t, err)
+	assert.InDelta(t, 36, speed, 0.1)
+}
+
+func TestCollector_TextFormat(t *testing.T) {
+	assert := assert.New(t)
+	sample := regexp.MustCompile(`^([a-z_]+)(\{[a-z]+="[^"]*"\})? [-+0-9.e]+$`)
+
+	// Given a collector of the events of two drones
+	collector := NewCollector(CollectorConfig{Now: func() time.Time { return testStart }})
+	for _, event := range testEvents() {
+		collector.Handle(event)
+	}
+
+	// When it is scraped
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	response, err := server.Client().Get(server.URL)
+	assert.NoError(err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(err)
+
+	// Then every sample should follow the HELP and TYPE of its metric
+	assert.Equal(ContentType, response.Header.Get("Content-Type"))
+	typed := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			typed[strings.Fields(line)[2]] = true
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		match := sample.FindStringSubmatch(line)
+		if assert.NotNil(match, line) {
+			name := match[1]
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				if base := strings.TrimSuffix(name, suffix); typed[base] {
+					name = base
+				}
+			}
+			assert.True(typed[name], "no TYPE for %s", line)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// network answers questions about the tube network: the neighbours of a station, the shortest path between two
+// stations, the stations of a line, and the traffic reported per line and per segment in a journaled run
+func network(args []string) int {
+	flags := flag.NewFlagSet("network", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), `usage: simulation network neighbours <station>
+       simulation network path <station> <station>
+       simulation network line <line>
+       simulation network lines
+       simulation network traffic <journal>`)
+		flags.PrintDefaults()
+	}
+	formats := addFormatFlags(flags)
+	flags.Parse(args)
+
+	query, arity := flags.Arg(0), map[string]int{"neighbours": 1, "path": 2, "line": 1, "lines": 0, "traffic": 1}
+	if expected, ok := arity[query]; !ok || flags.NArg() != expected+1 {
+		flags.Usage()
+		return 2
+	}
+
+	format, err := formats.parse()
+	if err != nil {
+		logrus.Error(err)
+		return 2
+	}
+	graph, err := store.LoadGraph(format.stations(store.Lenient), format.segments(store.Lenient))
+	if err != nil {
+		logrus.Errorf("Could not read network: %s", err)
+		return 1
+	}
+
+	switch query {
+	case "neighbours":
+		if _, ok := graph.Station(flags.Arg(1)); !ok {
+			logrus.Errorf("Unknown station %q", flags.Arg(1))
+			return 1
+		}
+		for _, name := range graph.Neighbours(flags.Arg(1)) {
+			fmt.Println(name)
+		}
+	case "path":
+		path, km, err := graph.ShortestPath(flags.Arg(1), flags.Arg(2))
+		if err != nil {
+			logrus.Error(err)
+			return 1
+		}
+		for _, segment := range path {
+			fmt.Println(segment)
+		}
+		fmt.Printf("%d stops, %.1f km\n", len(path), km)
+	case "line":
+		stations := graph.StationsOnLine(flags.Arg(1))
+		if len(stations) == 0 {
+			logrus.Errorf("Unknown line %q", flags.Arg(1))
+			return 1
+		}
+		for _, name := range stations {
+			fmt.Println(name)
+		}
+	case "lines":
+		for _, line := range graph.Lines() {
+			fmt.Println(line)
+		}
+	case "traffic":
+		events, err := readJournal(flags.Arg(1))
+		if err != nil {
+			logrus.Errorf("Could not read journal: %s", err)
+			return 1
+		}
+		recorder := agents.NewRecorder()
+		for _, event := range events {
+			recorder.Handle(event)
+		}
+		printNetworkTraffic(graph.AggregateTraffic(recorder.Reports()))
+	}
+	return 0
+}
+
+// printNetworkTraffic prints the reports counted on every line, then on every segment, the busiest first
+func printNetworkTraffic(traffic store.NetworkTraffic) {
+	lines := make([]string, 0, len(traffic.Lines))
+	for line := range traffic.Lines {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := traffic.Lines[lines[i]].Total(), traffic.Lines[lines[j]].Total()
+		return a > b || (a == b && lines[i] < lines[j])
+	})
+	for _, line := range lines {
+		fmt.Printf("%s: %s\n", line, formatCounts(traffic.Lines[line]))
+	}
+
+	segments := make([]store.Segment, 0, len(traffic.Segments))
+	for segment := range traffic.Segments {
+		segments = append(segments, segment)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		a, b := traffic.Segments[segments[i]].Total(), traffic.Segments[segments[j]].Total()
+		return a > b || (a == b && segments[i].String() < segments[j].String())
+	})
+	for _, segment := range segments {
+		fmt.Printf("  %s: %s\n", segment, formatCounts(traffic.Segments[segment]))
+	}
+}
+
+// formatCounts returns the number of reports of every condition, e.g. "3 reports, 2 HEAVY, 1 LIGHT"
+func formatCounts(counts store.TrafficCounts) string {
+	text := fmt.Sprintf("%d reports", counts.Total())
+	for _, condition := range []string{store.TrafficHeavy, store.TrafficModerate, store.TrafficLight} {
+		if counts[condition] > 0 {
+			text += fmt.Sprintf(", %d %s", counts[condition], condition)
+		}
+	}
+	return text
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"drone_simulation/agents"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// replay reproduces the log output of the drones of a journaled run
+func replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: simulation replay <journal>")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	events, err := readJournal(flags.Arg(0))
+	if err != nil {
+		logrus.Error(err)
+		return 1
+	}
+
+	agents.Replay(events, nil)
+	return 0
+}
+
+// diff compares the events of each drone in two journals
+func diff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: simulation diff <journal> <journal>")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	a, err := readJournal(flags.Arg(0))
+	if err != nil {
+		logrus.Error(err)
+		return 1
+	}
+	b, err := readJournal(flags.Arg(1))
+	if err != nil {
+		logrus.Error(err)
+		return 1
+	}
+
+	diffs := agents.DiffJournals(a, b)
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func readJournal(path string) ([]agents.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return agents.ReadJournal(file)
+}
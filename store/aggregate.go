@@ -0,0 +1,174 @@
+package store
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trafficConditions are the traffic conditions in decreasing order of severity
+var trafficConditions = []string{TrafficHeavy, TrafficModerate, TrafficLight}
+
+// TrafficWindow rolls up the traffic reports made at a station during a window of time
+type TrafficWindow struct {
+	Start time.Time
+	End   time.Time
+	// Counts counts the reports of the window by condition
+	Counts TrafficCounts
+	// Latest is the condition last reported in the window
+	Latest string
+	// Drones counts the reports of the window by drone
+	Drones map[int]int
+}
+
+// TrafficAggregator keeps the time series of the traffic reported at every station. It is safe to add reports
+// while querying it.
+type TrafficAggregator struct {
+	mu sync.Mutex
+	// series holds the reports made at every station, ordered by time
+	series map[string][]TrafficReport
+}
+
+// NewTrafficAggregator returns an empty aggregator
+func NewTrafficAggregator() *TrafficAggregator {
+	return &TrafficAggregator{series: map[string][]TrafficReport{}}
+}
+
+// Add adds a report to the time series of its station, after the reports made at the same time or before
+func (a *TrafficAggregator) Add(report TrafficReport) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	series := a.series[report.Station]
+	i := sort.Search(len(series), func(i int) bool { return series[i].Time.After(report.Time) })
+	a.series[report.Station] = append(series[:i], append([]TrafficReport{report}, series[i:]...)...)
+}
+
+// Stations returns the names of the stations reported at, sorted
+func (a *TrafficAggregator) Stations() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stations := make([]string, 0, len(a.series))
+	for station := range a.series {
+		stations = append(stations, station)
+	}
+	sort.Strings(stations)
+	return stations
+}
+
+// Series returns the reports made at a station, ordered by time
+func (a *TrafficAggregator) Series(station string) []TrafficReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]TrafficReport(nil), a.series[station]...)
+}
+
+// Counts counts the reports made at a station by condition
+func (a *TrafficAggregator) Counts(station string) TrafficCounts {
+	counts := TrafficCounts{}
+	for _, report := range a.Series(station) {
+		counts[report.Condition]++
+	}
+	return counts
+}
+
+// Latest returns the last report made at a station, or false if none was
+func (a *TrafficAggregator) Latest(station string) (TrafficReport, bool) {
+	series := a.Series(station)
+	if len(series) == 0 {
+		return TrafficReport{}, false
+	}
+	return series[len(series)-1], true
+}
+
+// Contributions counts the reports made at a station by drone and condition
+func (a *TrafficAggregator) Contributions(station string) map[int]TrafficCounts {
+	contributions := map[int]TrafficCounts{}
+	for _, report := range a.Series(station) {
+		if contributions[report.DroneID] == nil {
+			contributions[report.DroneID] = TrafficCounts{}
+		}
+		contributions[report.DroneID][report.Condition]++
+	}
+	return contributions
+}
+
+// Rollup rolls up the reports made at a station into consecutive windows of given length, aligned on multiples of
+// it since the zero time, e.g. on the hour and every 5 minutes for 5-minute windows. Windows without reports are
+// left out. If window is not positive, the reports are rolled up into a single window from the first to the last.
+func (a *TrafficAggregator) Rollup(station string, window time.Duration) []TrafficWindow {
+	var windows []TrafficWindow
+	for _, report := range a.Series(station) {
+		start, end := report.Time.Truncate(window), report.Time.Truncate(window).Add(window)
+		if window <= 0 {
+			start, end = report.Time, report.Time
+			if len(windows) > 0 {
+				start = windows[0].Start
+			}
+		}
+
+		if len(windows) == 0 || (window > 0 && !windows[len(windows)-1].Start.Equal(start)) {
+			windows = append(windows, TrafficWindow{Start: start, Counts: TrafficCounts{}, Drones: map[int]int{}})
+		}
+		last := &windows[len(windows)-1]
+		last.End = end
+		last.Counts[report.Condition]++
+		last.Latest = report.Condition
+		last.Drones[report.DroneID]++
+	}
+	return windows
+}
+
+// WriteCSV writes the rollup of every station into windows of given length, one row per station and window with
+// its report counts by condition, its latest condition and its reports by drone, e.g. "5937=2|6043=1"
+func (a *TrafficAggregator) WriteCSV(w io.Writer, window time.Duration) error {
+	writer := csv.NewWriter(w)
+	header := []string{"station", "start", "end", "reports"}
+	for _, condition := range trafficConditions {
+		header = append(header, strings.ToLower(condition))
+	}
+	if err := writer.Write(append(header, "latest", "drones")); err != nil {
+		return err
+	}
+
+	for _, station := range a.Stations() {
+		for _, rollup := range a.Rollup(station, window) {
+			row := []string{
+				station,
+				rollup.Start.Format(time.RFC3339),
+				rollup.End.Format(time.RFC3339),
+				strconv.Itoa(rollup.Counts.Total()),
+			}
+			for _, condition := range trafficConditions {
+				row = append(row, strconv.Itoa(rollup.Counts[condition]))
+			}
+			if err := writer.Write(append(row, rollup.Latest, formatDroneCounts(rollup.Drones))); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatDroneCounts formats counts by drone as "id=count" pairs separated by |, ordered by drone ID
+func formatDroneCounts(counts map[int]int) string {
+	ids := make([]int, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	pairs := make([]string, len(ids))
+	for i, id := range ids {
+		pairs[i] = fmt.Sprintf("%d=%d", id, counts[id])
+	}
+	return strings.Join(pairs, "|")
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAggregator() *TrafficAggregator {
+	start := time.Date(2011, 3, 22, 7, 58, 0, 0, time.UTC)
+	aggregator := NewTrafficAggregator()
+	for _, report := range []TrafficReport{
+		{DroneID: 5937, Station: "Bank", Time: start.Add(3 * time.Minute), Condition: TrafficLight},
+		{DroneID: 6043, Station: "Bank", Time: start, Condition: TrafficHeavy},
+		{DroneID: 5937, Station: "Bank", Time: start.Add(time.Minute), Condition: TrafficHeavy},
+		{DroneID: 6043, Station: "Angel", Time: start.Add(8 * time.Minute), Condition: TrafficModerate},
+		{DroneID: 5937, Station: "Bank", Time: start.Add(3 * time.Minute), Condition: TrafficModerate},
+	} {
+		aggregator.Add(report)
+	}
+	return aggregator
+}
+
+func TestTrafficAggregator(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given reports added out of order
+	aggregator := newTestAggregator()
+
+	// Then
+	assert.Equal([]string{"Angel", "Bank"}, aggregator.Stations())
+	var conditions []string
+	for _, report := range aggregator.Series("Bank") {
+		conditions = append(conditions, report.Condition)
+	}
+	assert.Equal([]string{TrafficHeavy, TrafficHeavy, TrafficLight, TrafficModerate}, conditions, "reports made at the same time should stay in the order they were added")
+	assert.Equal(TrafficCounts{TrafficHeavy: 2, TrafficLight: 1, TrafficModerate: 1}, aggregator.Counts("Bank"))
+
+	latest, ok := aggregator.Latest("Bank")
+	assert.True(ok)
+	assert.Equal(TrafficModerate, latest.Condition)
+	_, ok = aggregator.Latest("Oval")
+	assert.False(ok)
+
+	assert.Equal(map[int]TrafficCounts{
+		5937: {TrafficHeavy: 1, TrafficLight: 1, TrafficModerate: 1},
+		6043: {TrafficHeavy: 1},
+	}, aggregator.Contributions("Bank"))
+}
+
+func TestTrafficAggregator_Rollup(t *testing.T) {
+	aggregator := newTestAggregator()
+	at := func(hour, minute int) time.Time { return time.Date(2011, 3, 22, hour, minute, 0, 0, time.UTC) }
+
+	testCases := []struct {
+		name           string
+		window         time.Duration
+		expectedOutput []TrafficWindow
+	}{
+		{
+			name:   "Rollup() should roll the reports up into aligned windows",
+			window: 5 * time.Minute,
+			expectedOutput: []TrafficWindow{
+				{Start: at(7, 55), End: at(8, 0), Counts: TrafficCounts{TrafficHeavy: 2}, Latest: TrafficHeavy, Drones: map[int]int{5937: 1, 6043: 1}},
+				{Start: at(8, 0), End: at(8, 5), Counts: TrafficCounts{TrafficLight: 1, TrafficModerate: 1}, Latest: TrafficModerate, Drones: map[int]int{5937: 2}},
+			},
+		},
+		{
+			name: "Rollup() should roll every report up into one window without a window length",
+			expectedOutput: []TrafficWindow{
+				{Start: at(7, 58), End: at(8, 1), Counts: TrafficCounts{TrafficHeavy: 2, TrafficLight: 1, TrafficModerate: 1}, Latest: TrafficModerate, Drones: map[int]int{5937: 3, 6043: 1}},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedOutput, aggregator.Rollup("Bank", testCase.window))
+		})
+	}
+}
+
+func TestTrafficAggregator_WriteCSV(t *testing.T) {
+	// Given
+	aggregator := newTestAggregator()
+
+	// When
+	var buffer bytes.Buffer
+	assert.NoError(t, aggregator.WriteCSV(&buffer, 5*time.Minute))
+
+	// Then
+	assert.Equal(t, `station,start,end,reports,heavy,moderate,light,latest,drones
+Angel,2011-03-22T08:05:00Z,2011-03-22T08:10:00Z,1,0,1,0,MODERATE,6043=1
+Bank,2011-03-22T07:55:00Z,2011-03-22T08:00:00Z,2,2,0,0,HEAVY,5937=1|6043=1
+Bank,2011-03-22T08:00:00Z,2011-03-22T08:05:00Z,2,0,1,1,MODERATE,5937=2
+`, buffer.String())
+}
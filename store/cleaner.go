@@ -0,0 +1,205 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/umahmood/haversine"
+)
+
+const (
+	defaultMaxTimeGap  = 30 * time.Second
+	defaultMaxSpeedKph = 200
+)
+
+// CleaningStep defines one step of a RouteCleaner, returning the cleaned route and recording its changes in the report
+type CleaningStep func(route []Location, report *CleaningReport) []Location
+
+// RemovedLocation defines a location dropped from a route while cleaning it
+type RemovedLocation struct {
+	Location Location
+	Step     string
+	Reason   string
+}
+
+// TimeGap defines two consecutive locations of a route further apart in time than expected
+type TimeGap struct {
+	From Location
+	To   Location
+}
+
+// CleaningReport describes what a RouteCleaner changed in a route
+type CleaningReport struct {
+	Input     int
+	Output    int
+	Reordered int
+	Removed   []RemovedLocation
+	Gaps      []TimeGap
+}
+
+// Changed reports whether cleaning changed the route
+func (r CleaningReport) Changed() bool {
+	return r.Reordered > 0 || len(r.Removed) > 0
+}
+
+// String summarises the report
+func (r CleaningReport) String() string {
+	return fmt.Sprintf("%d locations in, %d out, %d reordered, %d removed, %d time gaps",
+		r.Input, r.Output, r.Reordered, len(r.Removed), len(r.Gaps))
+}
+
+// RouteCleaner validates and cleans routes by running a pipeline of steps in order
+type RouteCleaner struct {
+	steps []CleaningStep
+}
+
+// NewRouteCleaner returns a cleaner running the given steps in order
+func NewRouteCleaner(steps ...CleaningStep) *RouteCleaner {
+	return &RouteCleaner{steps: steps}
+}
+
+// DefaultRouteCleaner returns a cleaner that sorts a route, drops duplicates and impossible jumps, and reports time gaps.
+// Locations sharing a timestamp are kept, as timestamps have a resolution of a second and drones may report more often.
+func DefaultRouteCleaner() *RouteCleaner {
+	return NewRouteCleaner(
+		SortByTime(),
+		RemoveConsecutiveDuplicates(),
+		RejectImpossibleJumps(defaultMaxSpeedKph),
+		DetectTimeGaps(defaultMaxTimeGap),
+	)
+}
+
+// Clean returns a cleaned copy of a route and a report of what was changed
+func (c *RouteCleaner) Clean(route []Location) ([]Location, CleaningReport) {
+	report := CleaningReport{Input: len(route)}
+
+	cleaned := make([]Location, len(route))
+	copy(cleaned, route)
+	for _, step := range c.steps {
+		cleaned = step(cleaned, &report)
+	}
+
+	report.Output = len(cleaned)
+	return cleaned, report
+}
+
+// SortByTime orders a route by timestamp, keeping the file order of locations with the same timestamp
+func SortByTime() CleaningStep {
+	return func(route []Location, report *CleaningReport) []Location {
+		for i := 1; i < len(route); i++ {
+			if route[i].Time.Before(route[i-1].Time) {
+				report.Reordered++
+			}
+		}
+
+		sort.SliceStable(route, func(i, j int) bool { return route[i].Time.Before(route[j].Time) })
+		return route
+	}
+}
+
+// DropDuplicateTimestamps keeps only the first of consecutive locations with the same timestamp
+func DropDuplicateTimestamps() CleaningStep {
+	return keepIf("drop duplicate timestamps", func(previous, location Location) string {
+		if location.Time.Equal(previous.Time) {
+			return "same timestamp as the previous location"
+		}
+		return ""
+	})
+}
+
+// RemoveConsecutiveDuplicates drops locations identical to the previous one
+func RemoveConsecutiveDuplicates() CleaningStep {
+	return keepIf("remove consecutive duplicates", func(previous, location Location) string {
+		if location == previous {
+			return "duplicate of the previous location"
+		}
+		return ""
+	})
+}
+
+// RejectImpossibleJumps drops outliers: locations that could only be reached from the previous location and left
+// for the next one faster than maxSpeedKph. Judging a location against both of its neighbours keeps a single bad
+// location from getting the rest of the route rejected, and keeps the first and last locations, which have only one.
+func RejectImpossibleJumps(maxSpeedKph float64) CleaningStep {
+	return func(route []Location, report *CleaningReport) []Location {
+		if len(route) < 3 {
+			return route
+		}
+
+		kept := route[:1]
+		for i, location := range route[1 : len(route)-1] {
+			previous, next := kept[len(kept)-1], route[i+2]
+			if speed := jumpSpeedKph(previous, location); speed > maxSpeedKph && jumpSpeedKph(location, next) > maxSpeedKph {
+				report.Removed = append(report.Removed, RemovedLocation{
+					Location: location,
+					Step:     "reject impossible jumps",
+					Reason: fmt.Sprintf("%.0f km/h from the previous location in %s, and to the next",
+						speed, location.Time.Sub(previous.Time)),
+				})
+				continue
+			}
+			kept = append(kept, location)
+		}
+		return append(kept, route[len(route)-1])
+	}
+}
+
+// jumpSpeedKph returns the lowest speed at which a drone could fly between two locations, allowing for the second
+// resolution of their timestamps
+func jumpSpeedKph(from, to Location) float64 {
+	_, distanceInKm := haversine.Distance(
+		haversine.Coord{Lat: from.Latitude, Lon: from.Longitude},
+		haversine.Coord{Lat: to.Latitude, Lon: to.Longitude},
+	)
+	elapsed := to.Time.Sub(from.Time)
+	if elapsed < 0 {
+		elapsed = -elapsed
+	}
+	return distanceInKm / (elapsed + time.Second).Hours()
+}
+
+// DetectTimeGaps reports consecutive locations more than maxGap apart, without changing the route
+func DetectTimeGaps(maxGap time.Duration) CleaningStep {
+	return func(route []Location, report *CleaningReport) []Location {
+		for i := 1; i < len(route); i++ {
+			if route[i].Time.Sub(route[i-1].Time) > maxGap {
+				report.Gaps = append(report.Gaps, TimeGap{From: route[i-1], To: route[i]})
+			}
+		}
+		return route
+	}
+}
+
+// keepIf returns a step dropping every location for which reject returns a reason, compared to the last location kept
+func keepIf(step string, reject func(previous, location Location) string) CleaningStep {
+	return func(route []Location, report *CleaningReport) []Location {
+		if len(route) == 0 {
+			return route
+		}
+
+		kept := route[:1]
+		for _, location := range route[1:] {
+			if reason := reject(kept[len(kept)-1], location); reason != "" {
+				report.Removed = append(report.Removed, RemovedLocation{Location: location, Step: step, Reason: reason})
+				continue
+			}
+			kept = append(kept, location)
+		}
+		return kept
+	}
+}
+
+func logCleaningReport(id int, report CleaningReport) {
+	logger := logrus.WithField("Drone", id)
+	for _, removed := range report.Removed {
+		logger.WithField("Time", removed.Location.Time.Format(time.TimeOnly)).
+			WithField("Step", removed.Step).
+			Debug(fmt.Sprintf("Removed location: %s", removed.Reason))
+	}
+
+	if report.Changed() {
+		logger.Info(fmt.Sprintf("Cleaned route: %s", report))
+	}
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteCleaner(t *testing.T) {
+	first := Location{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:55Z")}
+	second := Location{DroneID: 1234, Latitude: 51.474600, Longitude: -0.171800, Time: convertToTimeForTests("2011-03-22T07:48:01Z")}
+	third := Location{DroneID: 1234, Latitude: 51.474650, Longitude: -0.171750, Time: convertToTimeForTests("2011-03-22T07:48:07Z")}
+	late := Location{DroneID: 1234, Latitude: 51.474700, Longitude: -0.171700, Time: convertToTimeForTests("2011-03-22T07:50:00Z")}
+	jump := Location{DroneID: 1234, Latitude: 51.574600, Longitude: -0.171800, Time: convertToTimeForTests("2011-03-22T07:48:02Z")}
+	// far is 70 m from second within the same second of its timestamp, which a drone may fly in up to two seconds
+	far := Location{DroneID: 1234, Latitude: 51.475230, Longitude: -0.171800, Time: convertToTimeForTests("2011-03-22T07:48:02Z")}
+	lost := Location{DroneID: 1234, Latitude: 51.374579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:50Z")}
+
+	testCases := []struct {
+		name              string
+		cleaner           *RouteCleaner
+		input             []Location
+		expectedOutput    []Location
+		expectedReordered int
+		expectedRemoved   []string
+		expectedGaps      int
+	}{
+		{
+			name:              "SortByTime() should order locations by timestamp",
+			cleaner:           NewRouteCleaner(SortByTime()),
+			input:             []Location{second, first, third},
+			expectedOutput:    []Location{first, second, third},
+			expectedReordered: 1,
+		},
+		{
+			name:            "DropDuplicateTimestamps() should keep the first location with a timestamp",
+			cleaner:         NewRouteCleaner(DropDuplicateTimestamps()),
+			input:           []Location{first, {DroneID: 1234, Latitude: 1, Longitude: 2, Time: first.Time}, second},
+			expectedOutput:  []Location{first, second},
+			expectedRemoved: []string{"drop duplicate timestamps"},
+		},
+		{
+			name:            "RemoveConsecutiveDuplicates() should drop repeated locations",
+			cleaner:         NewRouteCleaner(RemoveConsecutiveDuplicates()),
+			input:           []Location{first, first, second, second, first},
+			expectedOutput:  []Location{first, second, first},
+			expectedRemoved: []string{"remove consecutive duplicates", "remove consecutive duplicates"},
+		},
+		{
+			name:            "RejectImpossibleJumps() should drop locations too far from both of their neighbours",
+			cleaner:         NewRouteCleaner(RejectImpossibleJumps(200)),
+			input:           []Location{first, second, jump, third},
+			expectedOutput:  []Location{first, second, third},
+			expectedRemoved: []string{"reject impossible jumps"},
+		},
+		{
+			name:           "RejectImpossibleJumps() should allow for the second resolution of timestamps",
+			cleaner:        NewRouteCleaner(RejectImpossibleJumps(200)),
+			input:          []Location{first, second, far, third},
+			expectedOutput: []Location{first, second, far, third},
+		},
+		{
+			name:           "RejectImpossibleJumps() should not reject the rest of a route after a bad first location",
+			cleaner:        NewRouteCleaner(RejectImpossibleJumps(200)),
+			input:          []Location{lost, first, second, third},
+			expectedOutput: []Location{lost, first, second, third},
+		},
+		{
+			name:           "DetectTimeGaps() should report gaps without changing the route",
+			cleaner:        NewRouteCleaner(DetectTimeGaps(30 * time.Second)),
+			input:          []Location{first, second, third, late},
+			expectedOutput: []Location{first, second, third, late},
+			expectedGaps:   1,
+		},
+		{
+			name:              "DefaultRouteCleaner() should run every step",
+			cleaner:           DefaultRouteCleaner(),
+			input:             []Location{second, first, first, jump, far, third, late},
+			expectedOutput:    []Location{first, second, far, third, late},
+			expectedReordered: 1,
+			expectedRemoved:   []string{"remove consecutive duplicates", "reject impossible jumps"},
+			expectedGaps:      1,
+		},
+		{
+			name:           "Clean() should handle an empty route",
+			cleaner:        DefaultRouteCleaner(),
+			input:          []Location{},
+			expectedOutput: []Location{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			input := make([]Location, len(testCase.input))
+			copy(input, testCase.input)
+			route, report := testCase.cleaner.Clean(testCase.input)
+
+			assert.Equal(t, testCase.expectedOutput, route)
+			assert.Equal(t, input, testCase.input, "the input route should not be modified")
+			assert.Equal(t, len(testCase.input), report.Input)
+			assert.Equal(t, len(testCase.expectedOutput), report.Output)
+			assert.Equal(t, testCase.expectedReordered, report.Reordered)
+			assert.Len(t, report.Gaps, testCase.expectedGaps)
+
+			var removed []string
+			for _, location := range report.Removed {
+				removed = append(removed, location.Step)
+			}
+			assert.Equal(t, testCase.expectedRemoved, removed)
+			assert.Equal(t, testCase.expectedReordered > 0 || len(testCase.expectedRemoved) > 0, report.Changed())
+		})
+	}
+}
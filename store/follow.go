@@ -0,0 +1,150 @@
+package store
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// followPollInterval is how often a followed file is checked for new lines, truncation and rotation
+var followPollInterval = 100 * time.Millisecond
+
+// maxFollowedRow is the size of a row beyond which an unterminated quoted field is no longer awaited
+const maxFollowedRow = 64 * 1024
+
+// ErrNotFollowable is returned when following a route file that is not a plain CSV file
+var ErrNotFollowable = errors.New("only plain CSV route files can be followed")
+
+// CheckFollowable returns ErrNotFollowable if the route file of a drone is in a format that cannot be followed, e.g.
+// JSON or gzip-compressed. A missing route file can be followed, as it may be created later.
+func CheckFollowable(id int) error {
+	filename := strconv.Itoa(id)
+	file, path, format, err := openAnyData(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if path != fmt.Sprintf("data/%s.csv", filename) || format != formatCSV {
+		return fmt.Errorf("%w, not %s", ErrNotFollowable, path)
+	}
+	return nil
+}
+
+// followCSV parses the rows of a data file like scanCSV, then keeps parsing the rows appended to it, like tail -f,
+// until no row was appended for idleTimeout, or forever if 0. A truncated file is read again from its start, and a
+// rotated file, replaced by a new one under the same name, is read from the start of the new one. A row is only
+// parsed once its line is complete, and once its last line is written if a quoted field spans several lines.
+func followCSV[T any](filename string, idleTimeout time.Duration, parse func(row []string) (*T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		path := fmt.Sprintf("data/%s.csv", filename)
+		logger := logrus.WithField("File", path)
+		lastRead := time.Now()
+		idle := func() bool { return idleTimeout > 0 && time.Since(lastRead) > idleTimeout }
+
+		file, err := os.Open(path)
+		for err != nil {
+			if !errors.Is(err, os.ErrNotExist) || idle() {
+				yield(zero, err)
+				return
+			}
+			time.Sleep(followPollInterval)
+			file, err = os.Open(path)
+		}
+		defer func() { file.Close() }()
+
+		reader := bufio.NewReader(file)
+		var offset int64
+		var partial string
+		line, rowLine := 0, 0
+		for {
+			text, err := reader.ReadString('\n')
+			offset += int64(len(text))
+			partial += text
+			if err == nil {
+				line++
+				lastRead = time.Now()
+				if rowLine == 0 {
+					rowLine = line
+				}
+				// an odd number of quotes leaves a quoted field open, which the next lines continue
+				if strings.Count(partial, `"`)%2 == 1 && len(partial) < maxFollowedRow {
+					continue
+				}
+				row, rowErr := csv.NewReader(strings.NewReader(partial)).Read()
+				first := rowLine
+				partial, rowLine = "", 0
+				if rowErr == io.EOF {
+					continue
+				}
+
+				item, parseErr := parseRow(row, rowErr, parse)
+				if parseErr != nil {
+					parseErr.File, parseErr.Line = path, first
+					if !yield(zero, parseErr) {
+						return
+					}
+					continue
+				}
+				if item != nil && !yield(*item, nil) {
+					return
+				}
+				continue
+			}
+			if err != io.EOF {
+				yield(zero, err)
+				return
+			}
+
+			if idle() {
+				logger.Info(fmt.Sprintf("No line appended for %s, ending route", idleTimeout))
+				return
+			}
+			time.Sleep(followPollInterval)
+
+			opened, err := file.Stat()
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if opened.Size() < offset {
+				logger.Info("File truncated, reading it again from its start")
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					yield(zero, err)
+					return
+				}
+				reader.Reset(file)
+				offset, partial, line, rowLine = 0, "", 0, 0
+				continue
+			}
+
+			current, err := os.Stat(path)
+			if err != nil || os.SameFile(opened, current) || opened.Size() > offset {
+				// the file is missing while it is being rotated, unchanged, or has lines left to read
+				continue
+			}
+			rotated, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			logger.Info("File rotated, reading the new one")
+			file.Close()
+			file = rotated
+			reader.Reset(file)
+			offset, partial, line, rowLine = 0, "", 0, 0
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRouteRepository_Follow(t *testing.T) {
+	assert := assert.New(t)
+	pollInterval := followPollInterval
+	followPollInterval = time.Millisecond
+	defer func() { followPollInterval = pollInterval }()
+
+	// Given a route file being written
+	writeTestData(t, "1234.csv", "1234,\"51.1\",\"-0.1\",\"2011-03-22 07:47:55\"\n", false)
+	path := filepath.Join("data", "1234.csv")
+	appendLine := func(line string) {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		assert.NoError(err)
+		defer file.Close()
+		_, err = file.WriteString(line)
+		assert.NoError(err)
+	}
+
+	// When it is followed
+	repo := DefaultRouteRepository{Follow: true, IdleTimeout: 200 * time.Millisecond}
+	assert.True(IsLive(repo))
+	locations := make(chan Location)
+	go func() {
+		defer close(locations)
+		for location, err := range repo.StreamRoute(1234) {
+			assert.NoError(err)
+			locations <- location
+		}
+	}()
+	latitude := func() float64 {
+		select {
+		case location := <-locations:
+			return location.Latitude
+		case <-time.After(time.Second):
+			return 0
+		}
+	}
+	assert.Equal(51.1, latitude())
+
+	// Then appended lines should be streamed once complete
+	appendLine("1234,\"51.2\",\"-0.1\",")
+	time.Sleep(10 * time.Millisecond)
+	appendLine("\"2011-03-22 07:47:56\"\n")
+	assert.Equal(51.2, latitude())
+
+	// Then a truncated file should be read again from its start
+	assert.NoError(os.WriteFile(path, []byte("1234,\"51.3\",\"-0.1\",\"2011-03-22 07:47:57\"\n"), 0o644))
+	assert.Equal(51.3, latitude())
+
+	// Then a rotated file should be read from the start of the new one
+	assert.NoError(os.Rename(path, path+".1"))
+	assert.NoError(os.WriteFile(path, []byte("1234,\"51.4\",\"-0.1\",\"2011-03-22 07:47:58\"\n"), 0o644))
+	assert.Equal(51.4, latitude())
+
+	// Then the route should end once no line was appended for the idle timeout
+	select {
+	case _, ok := <-locations:
+		assert.False(ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle route did not end")
+	}
+}
+
+func TestDefaultRouteRepository_FollowRows(t *testing.T) {
+	pollInterval := followPollInterval
+	followPollInterval = time.Millisecond
+	defer func() { followPollInterval = pollInterval }()
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		mode          ParseMode
+		content       string
+		expected      []Location
+		expectedError string
+	}{
+		{
+			name:     "GetRoute() should parse a row whose quoted field spans several lines",
+			mode:     Strict,
+			content:  "1234,\"51.1\",\"-0.1\",\"2011-03-22\n07:47:55\"\n1234,\"51.2\",\"-0.1\",\"2011-03-22\n07:47:56\"\n",
+			expected: []Location{{DroneID: 1234, Latitude: 51.1, Longitude: -0.1, Time: start}, {DroneID: 1234, Latitude: 51.2, Longitude: -0.1, Time: start.Add(time.Second)}},
+		},
+		{
+			name:     "GetRoute() should skip the rows that cannot be parsed in Lenient mode",
+			mode:     Lenient,
+			content:  "1234,\"north\",\"-0.1\",\"2011-03-22\n07:47:55\"\n1234,\"51.2\",\"-0.1\",\"2011-03-22\n07:47:56\"\n",
+			expected: []Location{{DroneID: 1234, Latitude: 51.2, Longitude: -0.1, Time: start.Add(time.Second)}},
+		},
+		{
+			name:          "GetRoute() should fail on the first line of a row that cannot be parsed in Strict mode",
+			mode:          Strict,
+			content:       "1234,\"51.1\",\"-0.1\",\"2011-03-22\n07:47:55\"\n1234,\"north\",\"-0.1\",\"2011-03-22\n07:47:56\"\n",
+			expectedError: "data/1234.csv:3",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given a followed route file whose timestamps span two lines
+			writeTestData(t, "1234.csv", testCase.content, false)
+			repo := DefaultRouteRepository{
+				Mode:        testCase.mode,
+				TimeFormat:  TimeFormat{Layouts: []string{"2006-01-02\n15:04:05"}},
+				Follow:      true,
+				IdleTimeout: 50 * time.Millisecond,
+			}
+
+			// When
+			route, err := repo.GetRoute(1234)
+
+			// Then
+			if testCase.expectedError != "" {
+				assert.ErrorContains(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, route)
+		})
+	}
+}
+
+func TestCheckFollowable(t *testing.T) {
+	// Given a CSV route file and a JSON one
+	writeTestData(t, "1234.csv", "1234,\"51.1\",\"-0.1\",\"2011-03-22 07:47:55\"\n", false)
+	addTestData(t, "4321.json", `[{"latitude": 51.1, "longitude": -0.1, "time": "2011-03-22 07:47:55"}]`, false)
+
+	// Then only the CSV file, or a file yet to be written, should be followed
+	assert.NoError(t, CheckFollowable(1234))
+	assert.NoError(t, CheckFollowable(5678))
+	assert.ErrorIs(t, CheckFollowable(4321), ErrNotFollowable)
+
+	// When the JSON route is followed
+	_, err := DefaultRouteRepository{Follow: true}.GetRoute(4321)
+	// Then it should fail rather than wait for a CSV file
+	assert.ErrorIs(t, err, ErrNotFollowable)
+}
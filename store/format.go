@@ -0,0 +1,157 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"strconv"
+)
+
+// dataFormat defines the format of a data file
+type dataFormat string
+
+const (
+	formatCSV  dataFormat = "csv"
+	formatJSON dataFormat = "json"
+	formatGPX  dataFormat = "gpx"
+	formatNMEA dataFormat = "nmea"
+)
+
+// dataExtensions are the extensions of the data files tried in order, and the formats they are read in. The
+// format of CSV files is sniffed from their content, so that a misnamed file is still read.
+var dataExtensions = []struct {
+	extension string
+	format    dataFormat
+}{
+	{".csv", ""},
+	{".json", formatJSON},
+	{".ndjson", formatJSON},
+	{".jsonl", formatJSON},
+	{".gpx", formatGPX},
+	{".nmea", formatNMEA},
+}
+
+// openAnyData opens the data file with given name in the first format found, see openData, and returns its format
+func openAnyData(filename string) (io.ReadCloser, string, dataFormat, error) {
+	var firstErr error
+	for _, data := range dataExtensions {
+		file, path, err := openData(filename, data.extension)
+		if errors.Is(err, os.ErrNotExist) {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, path, "", err
+		}
+
+		format := data.format
+		buffered := bufio.NewReader(file)
+		if format == "" {
+			format = sniffFormat(buffered)
+		}
+		return readCloser{buffered, file}, path, format, nil
+	}
+	return nil, "", "", firstErr
+}
+
+// sniffFormat guesses the format of a data file from its first character
+func sniffFormat(r *bufio.Reader) dataFormat {
+	switch firstByte(r) {
+	case '[', '{':
+		return formatJSON
+	case '<':
+		return formatGPX
+	case '$':
+		return formatNMEA
+	default:
+		return formatCSV
+	}
+}
+
+// firstByte returns the first character of a reader that is not blank, without consuming it, or 0 if there is none
+func firstByte(r *bufio.Reader) byte {
+	for n := 1; n <= r.Size(); n++ {
+		peeked, _ := r.Peek(n)
+		if len(peeked) < n {
+			return 0
+		}
+		switch c := peeked[n-1]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c
+		}
+	}
+	return 0
+}
+
+// streamData lazily parses a data file in whichever format it is found, with scan
+func streamData[T any](filename string, scan func(r io.Reader, path string, format dataFormat) iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		file, path, format, err := openAnyData(filename)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		defer file.Close()
+
+		for item, err := range scan(file, path, format) {
+			if !yield(item, err) {
+				return
+			}
+		}
+	}
+}
+
+// streamRoute lazily parses the route file of a drone in whichever format it is found. The locations of JSON
+// routes without a drone ID are given the drone's.
+func (f TimeFormat) streamRoute(id int, fields JSONFields) iter.Seq2[Location, error] {
+	return streamData(strconv.Itoa(id), func(r io.Reader, path string, format dataFormat) iter.Seq2[Location, error] {
+		switch format {
+		case formatJSON:
+			defaults := map[string]string{locationFields[0]: strconv.Itoa(id)}
+			return scanJSON(r, path, func(object map[string]json.RawMessage) ([]string, *ParseError) {
+				return fields.row(object, locationFields, defaults)
+			}, f.parseLocation)
+		case formatGPX:
+			return scanGPX(r, path, id)
+		case formatNMEA:
+			return scanNMEA(r, path, id)
+		default:
+			return scanCSV(r, path, f.parseLocation)
+		}
+	})
+}
+
+// streamStations lazily parses the stations file in CSV, with or without a header, or JSON
+func streamStations(fields JSONFields) iter.Seq2[Station, error] {
+	return streamData(stationsFilename, func(r io.Reader, path string, format dataFormat) iter.Seq2[Station, error] {
+		if format == formatJSON {
+			optional := map[string]string{}
+			for _, field := range stationFields[requiredStationFields:] {
+				optional[field] = ""
+			}
+			return scanJSON(r, path, func(object map[string]json.RawMessage) ([]string, *ParseError) {
+				return fields.row(object, stationFields, optional)
+			}, parseStation)
+		}
+		return scanCSV(r, path, (&stationParser{}).parse)
+	})
+}
+
+// streamSegments lazily parses the segments file in CSV or JSON
+func streamSegments(fields JSONFields) iter.Seq2[Segment, error] {
+	return streamData(segmentsFilename, func(r io.Reader, path string, format dataFormat) iter.Seq2[Segment, error] {
+		if format == formatJSON {
+			return scanJSON(r, path, func(object map[string]json.RawMessage) ([]string, *ParseError) {
+				return fields.row(object, segmentFields, nil)
+			}, parseSegment)
+		}
+		return scanCSV(r, path, parseSegment)
+	})
+}
@@ -0,0 +1,4 @@
+package store
+
+// earthRadiusKm is the mean radius of the Earth, which the distances and projections of the maps are computed on
+const earthRadiusKm = 6371.0
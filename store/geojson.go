@@ -0,0 +1,104 @@
+package store
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// GeoJSONFeature defines a GeoJSON feature with a Point or LineString geometry
+type GeoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   GeoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+// GeoJSONGeometry defines a Point, with a single [longitude, latitude] position, or a LineString
+type GeoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// GeoJSONFeatureCollection defines a GeoJSON document
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// RouteFeature returns a route as a LineString feature, with the time of every vertex in its "times" property. A
+// route of a single location is a Point, as a LineString needs two positions, and an empty route is no feature.
+func RouteFeature(route []Location) (GeoJSONFeature, bool) {
+	if len(route) == 0 {
+		return GeoJSONFeature{}, false
+	}
+
+	coordinates := make([][2]float64, len(route))
+	times := make([]string, len(route))
+	for i, location := range route {
+		coordinates[i] = [2]float64{location.Longitude, location.Latitude}
+		times[i] = location.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	properties := map[string]any{"kind": "route", "times": times, "drone": route[0].DroneID}
+	if len(route) == 1 {
+		return pointFeature(route[0].Latitude, route[0].Longitude, properties), true
+	}
+	return GeoJSONFeature{
+		Type:       "Feature",
+		Geometry:   GeoJSONGeometry{Type: "LineString", Coordinates: coordinates},
+		Properties: properties,
+	}, true
+}
+
+// StationFeature returns a station as a Point feature with its name, and what is known of its service
+func StationFeature(station Station) GeoJSONFeature {
+	properties := map[string]any{"kind": "station", "name": station.Name, "step_free": station.StepFree}
+	if len(station.Lines) > 0 {
+		properties["lines"] = station.Lines
+	}
+	if station.Zone != "" {
+		properties["zone"] = station.Zone
+	}
+	if station.Network != "" {
+		properties["network"] = station.Network
+	}
+	return pointFeature(station.Latitude, station.Longitude, properties)
+}
+
+// ReportFeature returns a traffic report as a Point feature at its station, with its condition, speed, drone and time
+func ReportFeature(report TrafficReport) GeoJSONFeature {
+	return pointFeature(report.Latitude, report.Longitude, map[string]any{
+		"kind":      "report",
+		"station":   report.Station,
+		"condition": report.Condition,
+		"speed_kph": report.SpeedKph,
+		"drone":     report.DroneID,
+		"time":      report.Time.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func pointFeature(latitude, longitude float64, properties map[string]any) GeoJSONFeature {
+	return GeoJSONFeature{
+		Type:       "Feature",
+		Geometry:   GeoJSONGeometry{Type: "Point", Coordinates: [2]float64{longitude, latitude}},
+		Properties: properties,
+	}
+}
+
+// WriteGeoJSON writes routes, stations and traffic reports to a single GeoJSON feature collection
+func WriteGeoJSON(w io.Writer, routes [][]Location, stations []Station, reports []TrafficReport) error {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for _, route := range routes {
+		if feature, ok := RouteFeature(route); ok {
+			collection.Features = append(collection.Features, feature)
+		}
+	}
+	for _, station := range stations {
+		collection.Features = append(collection.Features, StationFeature(station))
+	}
+	for _, report := range reports {
+		collection.Features = append(collection.Features, ReportFeature(report))
+	}
+
+	return json.NewEncoder(w).Encode(collection)
+}
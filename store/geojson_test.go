@@ -0,0 +1,111 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteGeoJSON(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := []Location{
+		{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: start},
+		{DroneID: 1234, Latitude: 51.478935, Longitude: -0.172237, Time: start.Add(time.Second)},
+	}
+	station := Station{Name: "Acton Town", Latitude: 51.503071, Longitude: -0.280303}
+	report := TrafficReport{DroneID: 1234, Station: "Acton Town", Latitude: 51.503071, Longitude: -0.280303,
+		Time: start, SpeedKph: 42.5, Condition: TrafficHeavy}
+
+	// When a route, a station and a report are written to GeoJSON
+	var buffer bytes.Buffer
+	assert.NoError(WriteGeoJSON(&buffer, [][]Location{route}, []Station{station}, []TrafficReport{report}))
+
+	// Then each should be a feature with [longitude, latitude] coordinates and its properties
+	var collection struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]any
+		}
+	}
+	assert.NoError(json.Unmarshal(buffer.Bytes(), &collection))
+	assert.Equal("FeatureCollection", collection.Type)
+	assert.Len(collection.Features, 3)
+
+	line := collection.Features[0]
+	assert.Equal("LineString", line.Geometry.Type)
+	assert.JSONEq(`[[-0.171834,51.474579],[-0.172237,51.478935]]`, string(line.Geometry.Coordinates))
+	assert.Equal([]any{"2011-03-22T07:47:55Z", "2011-03-22T07:47:56Z"}, line.Properties["times"])
+	assert.Equal(float64(1234), line.Properties["drone"])
+
+	point := collection.Features[1]
+	assert.Equal("Point", point.Geometry.Type)
+	assert.JSONEq(`[-0.280303,51.503071]`, string(point.Geometry.Coordinates))
+	assert.Equal("Acton Town", point.Properties["name"])
+
+	assert.Equal(map[string]any{
+		"kind":      "report",
+		"station":   "Acton Town",
+		"condition": "HEAVY",
+		"speed_kph": 42.5,
+		"drone":     float64(1234),
+		"time":      "2011-03-22T07:47:55Z",
+	}, collection.Features[2].Properties)
+}
+
+func TestRouteFeature(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	location := Location{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: start}
+
+	testCases := []struct {
+		name                string
+		route               []Location
+		expectedOK          bool
+		expectedType        string
+		expectedCoordinates string
+	}{
+		{
+			name:                "RouteFeature() should return a route of several locations as a LineString",
+			route:               []Location{location, {DroneID: 1234, Latitude: 51.478935, Longitude: -0.172237, Time: start.Add(time.Second)}},
+			expectedOK:          true,
+			expectedType:        "LineString",
+			expectedCoordinates: `[[-0.171834,51.474579],[-0.172237,51.478935]]`,
+		},
+		{
+			name:                "RouteFeature() should return a route of a single location as a Point",
+			route:               []Location{location},
+			expectedOK:          true,
+			expectedType:        "Point",
+			expectedCoordinates: `[-0.171834,51.474579]`,
+		},
+		{
+			name:  "RouteFeature() should return no feature for an empty route",
+			route: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When
+			feature, ok := RouteFeature(testCase.route)
+
+			// Then
+			assert.Equal(t, testCase.expectedOK, ok)
+			if !ok {
+				return
+			}
+			coordinates, err := json.Marshal(feature.Geometry.Coordinates)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedType, feature.Geometry.Type)
+			assert.JSONEq(t, testCase.expectedCoordinates, string(coordinates))
+			assert.Equal(t, 1234, feature.Properties["drone"])
+		})
+	}
+}
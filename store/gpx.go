@@ -0,0 +1,175 @@
+package store
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+// GPXRouteRepository implements RouteRepository reading the track points of GPX files, data/<id>.gpx or its
+// gzip-compressed version
+type GPXRouteRepository struct {
+	// Mode defines how track points that cannot be parsed are handled, they are skipped with a warning by default
+	Mode ParseMode
+	// Cleaner validates and cleans every route read, if set
+	Cleaner *RouteCleaner
+}
+
+// GetRoute returns the track points of every track of a GPX file as the route of the drone with given ID
+func (r GPXRouteRepository) GetRoute(id int) ([]Location, error) {
+	return loadRoute(id, streamGPX(id), r.Mode, r.Cleaner)
+}
+
+// StreamRoute streams the track points of a GPX file as they are parsed, see DefaultRouteRepository.StreamRoute
+func (r GPXRouteRepository) StreamRoute(id int) iter.Seq2[Location, error] {
+	if r.Cleaner != nil {
+		return streamLoaded(r, id)
+	}
+
+	return skipUnparsed(streamGPX(id), r.Mode)
+}
+
+// streamGPX lazily parses the track points of the GPX file of a drone, see scanGPX
+func streamGPX(id int) iter.Seq2[Location, error] {
+	return func(yield func(Location, error) bool) {
+		file, path, err := openData(strconv.Itoa(id), ".gpx")
+		if err != nil {
+			yield(Location{}, err)
+			return
+		}
+		defer file.Close()
+
+		for location, err := range scanGPX(file, path, id) {
+			if !yield(location, err) {
+				return
+			}
+		}
+	}
+}
+
+// scanGPX lazily parses the trkpt elements of a GPX document into locations of the drone with given ID. A track
+// point that cannot be parsed yields a *ParseError and scanning goes on, a malformed document ends the sequence.
+func scanGPX(r io.Reader, path string, droneID int) iter.Seq2[Location, error] {
+	return func(yield func(Location, error) bool) {
+		decoder := xml.NewDecoder(r)
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Location{}, fmt.Errorf("%s: %w", path, err))
+				return
+			}
+
+			start, ok := token.(xml.StartElement)
+			if !ok || start.Name.Local != "trkpt" {
+				continue
+			}
+
+			line, _ := decoder.InputPos()
+			var point gpxPoint
+			if err := decoder.DecodeElement(&point, &start); err != nil {
+				yield(Location{}, fmt.Errorf("%s: %w", path, err))
+				return
+			}
+
+			location, parseErr := point.location(droneID)
+			if parseErr != nil {
+				parseErr.File, parseErr.Line = path, line
+				if !yield(Location{}, parseErr) {
+					return
+				}
+				continue
+			}
+			if !yield(location, nil) {
+				return
+			}
+		}
+	}
+}
+
+// WriteGPX writes routes to a GPX document, each as a track named after its drone
+func WriteGPX(w io.Writer, routes ...[]Location) error {
+	document := gpxDocument{Version: "1.1", Creator: "drone_simulation", Namespace: gpxNamespace}
+	for _, route := range routes {
+		var track gpxTrack
+		if len(route) > 0 {
+			track.Name = fmt.Sprintf("Drone %d", route[0].DroneID)
+		}
+
+		var segment gpxSegment
+		for _, location := range route {
+			segment.Points = append(segment.Points, gpxPoint{
+				Latitude:  strconv.FormatFloat(location.Latitude, 'f', -1, 64),
+				Longitude: strconv.FormatFloat(location.Longitude, 'f', -1, 64),
+				Time:      location.Time.UTC().Format(time.RFC3339Nano),
+			})
+		}
+		track.Segments = []gpxSegment{segment}
+		document.Tracks = append(document.Tracks, track)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type gpxDocument struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Namespace string     `xml:"xmlns,attr"`
+	Tracks    []gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Latitude  string `xml:"lat,attr"`
+	Longitude string `xml:"lon,attr"`
+	Time      string `xml:"time,omitempty"`
+}
+
+// location returns the location of a track point, or a diagnostic to be located in its file by the caller
+func (p gpxPoint) location(droneID int) (Location, *ParseError) {
+	latitude, reason := parseDegrees(p.Latitude, 90)
+	if reason != "" {
+		return Location{}, &ParseError{Field: "lat", Reason: reason}
+	}
+
+	longitude, reason := parseDegrees(p.Longitude, 180)
+	if reason != "" {
+		return Location{}, &ParseError{Field: "lon", Reason: reason}
+	}
+
+	if strings.TrimSpace(p.Time) == "" {
+		return Location{}, &ParseError{Field: "time", Reason: "missing time"}
+	}
+	time, err := TimeFormat{}.Parse(strings.TrimSpace(p.Time))
+	if err != nil {
+		return Location{}, &ParseError{Field: "time", Reason: err.Error()}
+	}
+
+	return Location{DroneID: droneID, Latitude: latitude, Longitude: longitude, Time: time}, nil
+}
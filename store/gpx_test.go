@@ -0,0 +1,107 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRouteGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="field team" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <name>Morning flight</name>
+    <trkseg>
+      <trkpt lat="51.474579" lon="-0.171834"><ele>12</ele><time>2011-03-22T07:47:55Z</time></trkpt>
+      <trkpt lat="hello" lon="-0.172361"><time>2011-03-22T07:48:01Z</time></trkpt>
+      <trkpt lat="51.478935" lon="-0.172237"></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="51.478935" lon="-0.172237"><time>2011-03-22T08:48:07+01:00</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>
+`
+
+func TestGPXRouteRepository_GetRoute(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mode           ParseMode
+		expectedOutput []Location
+		expectedError  string
+	}{
+		{
+			name: "GetRoute() should read the track points of every segment and skip invalid ones in Lenient mode",
+			mode: Lenient,
+			expectedOutput: []Location{
+				{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:55Z")},
+				{DroneID: 1234, Latitude: 51.478935, Longitude: -0.172237, Time: convertToTimeForTests("2011-03-22T07:48:07Z")},
+			},
+		},
+		{
+			name:          "GetRoute() should fail on the first invalid track point in Strict mode",
+			mode:          Strict,
+			expectedError: `data/1234.gpx:7: lat: "hello" is not a number`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			writeTestData(t, "1234.gpx", testRouteGPX, false)
+
+			// When
+			route, err := GPXRouteRepository{Mode: testCase.mode}.GetRoute(1234)
+
+			// Then
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, route, len(testCase.expectedOutput))
+			for i, location := range route {
+				assert.Equal(t, testCase.expectedOutput[i].Latitude, location.Latitude)
+				assert.Equal(t, testCase.expectedOutput[i].Longitude, location.Longitude)
+				assert.True(t, testCase.expectedOutput[i].Time.Equal(location.Time))
+			}
+		})
+	}
+}
+
+func TestScanGPX_Diagnostics(t *testing.T) {
+	// Given a GPX document with invalid track points
+	var diagnostics []string
+	for _, err := range scanGPX(bytes.NewBufferString(testRouteGPX), "test.gpx", 1234) {
+		if err != nil {
+			diagnostics = append(diagnostics, err.Error())
+		}
+	}
+
+	// Then each should be located in the document
+	assert.Equal(t, []string{
+		`test.gpx:7: lat: "hello" is not a number`,
+		`test.gpx:8: time: missing time`,
+	}, diagnostics)
+}
+
+func TestWriteGPX(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := []Location{
+		{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: start},
+		{DroneID: 1234, Latitude: 51.00001, Longitude: -0.172237, Time: start.Add(1500 * time.Millisecond)},
+	}
+
+	// When a route is written to GPX
+	var buffer bytes.Buffer
+	assert.NoError(WriteGPX(&buffer, route))
+
+	// Then it should name the drone, and be read back as it was
+	assert.Contains(buffer.String(), "<name>Drone 1234</name>")
+	assert.Contains(buffer.String(), `<trkpt lat="51.00001" lon="-0.172237">`)
+	assert.Contains(buffer.String(), "<time>2011-03-22T07:47:56.5Z</time>")
+	output, err := collect(scanGPX(&buffer, "test.gpx", 1234), Strict)
+	assert.NoError(err)
+	assert.Equal(route, output)
+}
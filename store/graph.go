@@ -0,0 +1,345 @@
+package store
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/umahmood/haversine"
+)
+
+const segmentsFilename = "tube-edges"
+
+var (
+	// ErrUnknownStation is returned when querying a station that is not in the network
+	ErrUnknownStation = errors.New("unknown station")
+	// ErrNoPath is returned when two stations are not connected by any line
+	ErrNoPath = errors.New("no path between stations")
+)
+
+// Segment is the stretch of a line between two adjacent stations, travelled in both directions
+type Segment struct {
+	Line string `json:"line"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// String returns the segment as "From - To (Line)"
+func (s Segment) String() string {
+	return fmt.Sprintf("%s - %s (%s)", s.From, s.To, s.Line)
+}
+
+// reversed returns the segment travelled in the other direction
+func (s Segment) reversed() Segment {
+	return Segment{Line: s.Line, From: s.To, To: s.From}
+}
+
+// segmentFields are the fields of a segment in the order of a segments file
+var segmentFields = []string{"line", "from", "to"}
+
+// parseSegment parses the line and the two stations of a segment
+func parseSegment(row []string) (*Segment, error) {
+	if err := requireFields(row, segmentFields...); err != nil {
+		return nil, err
+	}
+
+	for i, field := range segmentFields {
+		if strings.TrimSpace(row[i]) == "" {
+			return nil, fieldError(i+1, field, "must not be empty")
+		}
+	}
+	if row[1] == row[2] {
+		return nil, fieldError(3, segmentFields[2], fmt.Sprintf("%q links a station to itself", row[2]))
+	}
+	return &Segment{Line: strings.TrimSpace(row[0]), From: row[1], To: row[2]}, nil
+}
+
+// Graph is the tube network: stations linked by the segments of the lines serving them
+type Graph struct {
+	stations map[string]Station
+	segments []Segment
+	// given holds the segments in the direction they were given
+	given map[Segment]bool
+	// adjacency holds the segments leaving every station, in both directions of travel
+	adjacency map[string][]Segment
+}
+
+// NewGraph links stations by segments. The lines of a segment are added to the lines of its stations, and a segment
+// naming a station that is not in stations is an error.
+func NewGraph(stations []Station, segments []Segment) (*Graph, error) {
+	g := &Graph{stations: map[string]Station{}, given: map[Segment]bool{}, adjacency: map[string][]Segment{}}
+	for _, station := range stations {
+		station.Lines = append([]string(nil), station.Lines...)
+		g.stations[station.Name] = station
+	}
+
+	var errs []error
+	for _, segment := range segments {
+		unknown := false
+		for _, name := range []string{segment.From, segment.To} {
+			if _, ok := g.stations[name]; !ok {
+				errs = append(errs, fmt.Errorf("segment %s: %w %q", segment, ErrUnknownStation, name))
+				unknown = true
+			}
+		}
+		if unknown || g.given[segment] || g.given[segment.reversed()] {
+			continue
+		}
+		g.given[segment] = true
+
+		g.segments = append(g.segments, segment)
+		g.adjacency[segment.From] = append(g.adjacency[segment.From], segment)
+		g.adjacency[segment.To] = append(g.adjacency[segment.To], segment.reversed())
+		for _, name := range []string{segment.From, segment.To} {
+			station := g.stations[name]
+			if !slices.Contains(station.Lines, segment.Line) {
+				station.Lines = append(station.Lines, segment.Line)
+				g.stations[name] = station
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return g, nil
+}
+
+// LoadGraph returns the network of the stations of stationRepo linked by the segments of segmentRepo
+func LoadGraph(stationRepo StationRepository, segmentRepo SegmentRepository) (*Graph, error) {
+	stations, err := stationRepo.GetStations()
+	if err != nil {
+		return nil, err
+	}
+	segments, err := segmentRepo.GetSegments()
+	if err != nil {
+		return nil, err
+	}
+	return NewGraph(stations, segments)
+}
+
+// Station returns the station with given name
+func (g *Graph) Station(name string) (Station, bool) {
+	station, ok := g.stations[name]
+	return station, ok
+}
+
+// Segments returns every segment of the network, in the order they were given
+func (g *Graph) Segments() []Segment {
+	return append([]Segment(nil), g.segments...)
+}
+
+// Lines returns the names of the lines of the network, sorted
+func (g *Graph) Lines() []string {
+	lines := map[string]bool{}
+	for _, station := range g.stations {
+		for _, line := range station.Lines {
+			lines[line] = true
+		}
+	}
+	return sortedKeys(lines)
+}
+
+// Neighbours returns the names of the stations one segment away from a station on any line, sorted
+func (g *Graph) Neighbours(name string) []string {
+	neighbours := map[string]bool{}
+	for _, segment := range g.adjacency[name] {
+		neighbours[segment.To] = true
+	}
+	return sortedKeys(neighbours)
+}
+
+// StationsOnLine returns the names of the stations of a line in the order they are travelled from a terminus, one
+// branch after the other. Stations the segments of the line do not link are listed last, sorted.
+func (g *Graph) StationsOnLine(line string) []string {
+	var onLine []string
+	degree := map[string]int{}
+	for name, station := range g.stations {
+		if !slices.Contains(station.Lines, line) {
+			continue
+		}
+		onLine = append(onLine, name)
+		for _, segment := range g.adjacency[name] {
+			if segment.Line == line {
+				degree[name]++
+			}
+		}
+	}
+	sort.Strings(onLine)
+
+	var stations []string
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		visited[name] = true
+		stations = append(stations, name)
+		for _, segment := range g.adjacency[name] {
+			if segment.Line == line && !visited[segment.To] {
+				visit(segment.To)
+			}
+		}
+	}
+	// start every part of the line from its first terminus, or from its first station if it is a loop
+	for _, terminus := range []bool{true, false} {
+		for _, name := range onLine {
+			if !visited[name] && degree[name] > 0 && (degree[name] == 1 || !terminus) {
+				visit(name)
+			}
+		}
+	}
+	for _, name := range onLine {
+		if !visited[name] {
+			stations = append(stations, name)
+		}
+	}
+	return stations
+}
+
+// ShortestPath returns the segments of the shortest journey between two stations, in the order they are travelled,
+// and its length in km. Where lines run side by side, the journey stays on the line it is on.
+func (g *Graph) ShortestPath(from, to string) ([]Segment, float64, error) {
+	for _, name := range []string{from, to} {
+		if _, ok := g.stations[name]; !ok {
+			return nil, 0, fmt.Errorf("%w %q", ErrUnknownStation, name)
+		}
+	}
+
+	distances := map[string]float64{from: 0}
+	previous := map[string]Segment{}
+	queue := &distanceQueue{{name: from}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(queued)
+		if current.name == to {
+			break
+		}
+		if current.distance > distances[current.name] {
+			continue
+		}
+		for _, segment := range g.adjacency[current.name] {
+			distance := current.distance + g.length(segment)
+			if known, ok := distances[segment.To]; ok && known <= distance {
+				continue
+			}
+			distances[segment.To] = distance
+			previous[segment.To] = segment
+			heap.Push(queue, queued{name: segment.To, distance: distance})
+		}
+	}
+
+	if _, ok := distances[to]; !ok {
+		return nil, 0, fmt.Errorf("%w %q and %q", ErrNoPath, from, to)
+	}
+	var path []Segment
+	for name := to; name != from; name = previous[name].From {
+		path = append([]Segment{previous[name]}, path...)
+	}
+	for i := 1; i < len(path); i++ {
+		if g.serves(path[i-1].Line, path[i]) {
+			path[i].Line = path[i-1].Line
+		}
+	}
+	return path, distances[to], nil
+}
+
+// serves reports whether a line runs along a segment, in either direction
+func (g *Graph) serves(line string, segment Segment) bool {
+	for _, other := range g.adjacency[segment.From] {
+		if other.Line == line && other.To == segment.To {
+			return true
+		}
+	}
+	return false
+}
+
+// length returns the distance in km between the stations of a segment, as the crow flies
+func (g *Graph) length(segment Segment) float64 {
+	from, to := g.stations[segment.From], g.stations[segment.To]
+	_, km := haversine.Distance(
+		haversine.Coord{Lat: from.Latitude, Lon: from.Longitude},
+		haversine.Coord{Lat: to.Latitude, Lon: to.Longitude},
+	)
+	return km
+}
+
+// TrafficCounts counts the traffic reports by condition
+type TrafficCounts map[string]int
+
+// Total returns the number of reports counted
+func (c TrafficCounts) Total() int {
+	total := 0
+	for _, count := range c {
+		total += count
+	}
+	return total
+}
+
+// NetworkTraffic counts the traffic reports by line and by segment
+type NetworkTraffic struct {
+	Lines    map[string]TrafficCounts
+	Segments map[Segment]TrafficCounts
+}
+
+// AggregateTraffic counts every report made at a station on each line serving the station, and on each segment
+// ending at it. Segments are keyed as they were given to the graph. Reports at stations outside the network are
+// ignored.
+func (g *Graph) AggregateTraffic(reports []TrafficReport) NetworkTraffic {
+	traffic := NetworkTraffic{Lines: map[string]TrafficCounts{}, Segments: map[Segment]TrafficCounts{}}
+	for _, report := range reports {
+		station, ok := g.stations[report.Station]
+		if !ok {
+			continue
+		}
+		for _, line := range station.Lines {
+			if traffic.Lines[line] == nil {
+				traffic.Lines[line] = TrafficCounts{}
+			}
+			traffic.Lines[line][report.Condition]++
+		}
+		for _, segment := range g.adjacency[station.Name] {
+			if !g.given[segment] {
+				segment = segment.reversed()
+			}
+			if traffic.Segments[segment] == nil {
+				traffic.Segments[segment] = TrafficCounts{}
+			}
+			traffic.Segments[segment][report.Condition]++
+		}
+	}
+	return traffic
+}
+
+// queued is a station reached by the shortest path search, at a distance from its start
+type queued struct {
+	name     string
+	distance float64
+}
+
+// distanceQueue is a priority queue of stations, the closest first
+type distanceQueue []queued
+
+func (q distanceQueue) Len() int { return len(q) }
+func (q distanceQueue) Less(i, j int) bool {
+	if q[i].distance != q[j].distance {
+		return q[i].distance < q[j].distance
+	}
+	return q[i].name < q[j].name
+}
+func (q distanceQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *distanceQueue) Push(x any)   { *q = append(*q, x.(queued)) }
+func (q *distanceQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,272 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStations lie on a grid, about 1.1 km apart north to south
+var testStations = []Station{
+	{Name: "A", Latitude: 51.50, Longitude: -0.10},
+	{Name: "B", Latitude: 51.51, Longitude: -0.10},
+	{Name: "C", Latitude: 51.52, Longitude: -0.10},
+	{Name: "D", Latitude: 51.51, Longitude: -0.08, Lines: []string{"Blue"}},
+	{Name: "E", Latitude: 51.60, Longitude: -0.10},
+}
+
+var testSegments = []Segment{
+	{Line: "Red", From: "A", To: "B"},
+	{Line: "Red", From: "B", To: "C"},
+	{Line: "Blue", From: "B", To: "D"},
+	{Line: "Green", From: "A", To: "B"},
+	{Line: "Green", From: "D", To: "C"},
+	{Line: "Red", From: "C", To: "B"},
+}
+
+func newTestGraph(t *testing.T) *Graph {
+	graph, err := NewGraph(testStations, testSegments)
+	assert.NoError(t, err)
+	return graph
+}
+
+func TestParseSegment(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          []string
+		expectedOutput *Segment
+		expectedError  string
+	}{
+		{
+			name:           "parseSegment() should return a Segment",
+			input:          []string{" Victoria ", "Brixton", "Stockwell"},
+			expectedOutput: &Segment{Line: "Victoria", From: "Brixton", To: "Stockwell"},
+		},
+		{
+			name:          "parseSegment() should return an error if the input is missing fields",
+			input:         []string{"Victoria", "Brixton"},
+			expectedError: "missing field",
+		},
+		{
+			name:          "parseSegment() should return an error if the line is empty",
+			input:         []string{"", "Brixton", "Stockwell"},
+			expectedError: "column 1 (line): must not be empty",
+		},
+		{
+			name:          "parseSegment() should return an error if a station is linked to itself",
+			input:         []string{"Victoria", "Brixton", "Brixton"},
+			expectedError: `column 3 (to): "Brixton" links a station to itself`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			segment, err := parseSegment(testCase.input)
+
+			assert.Equal(t, testCase.expectedOutput, segment)
+			if testCase.expectedError != "" {
+				assert.ErrorContains(t, err, testCase.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewGraph(t *testing.T) {
+	t.Run("NewGraph() should add the lines of the segments to their stations", func(t *testing.T) {
+		// When
+		graph := newTestGraph(t)
+
+		// Then
+		b, ok := graph.Station("B")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"Red", "Blue", "Green"}, b.Lines)
+		d, _ := graph.Station("D")
+		assert.Equal(t, []string{"Blue", "Green"}, d.Lines)
+		assert.Equal(t, []string{"Blue", "Green", "Red"}, graph.Lines())
+		assert.Len(t, graph.Segments(), 5, "the reversed duplicate of B - C is dropped")
+		assert.Equal(t, []string{"Blue"}, testStations[3].Lines, "the given stations are left as they are")
+	})
+
+	t.Run("NewGraph() should fail on segments naming unknown stations", func(t *testing.T) {
+		// When
+		_, err := NewGraph(testStations, []Segment{{Line: "Red", From: "A", To: "Z"}, {Line: "Red", From: "Y", To: "A"}})
+
+		// Then
+		assert.ErrorIs(t, err, ErrUnknownStation)
+		assert.ErrorContains(t, err, `segment A - Z (Red): unknown station "Z"`)
+		assert.ErrorContains(t, err, `segment Y - A (Red): unknown station "Y"`)
+	})
+}
+
+func TestGraphQueries(t *testing.T) {
+	graph := newTestGraph(t)
+
+	t.Run("Neighbours() should return the stations one segment away on any line", func(t *testing.T) {
+		assert.Equal(t, []string{"A", "C", "D"}, graph.Neighbours("B"))
+		assert.Empty(t, graph.Neighbours("E"))
+		assert.Empty(t, graph.Neighbours("Z"))
+	})
+
+	testCases := []struct {
+		name           string
+		line           string
+		expectedOutput []string
+	}{
+		{name: "StationsOnLine() should list a line from its first terminus", line: "Red", expectedOutput: []string{"A", "B", "C"}},
+		{name: "StationsOnLine() should list the stations of a line known only from their own lines", line: "Blue", expectedOutput: []string{"B", "D"}},
+		{name: "StationsOnLine() should list one part of a line after the other", line: "Green", expectedOutput: []string{"A", "B", "C", "D"}},
+		{name: "StationsOnLine() should return nothing for an unknown line", line: "Purple"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedOutput, graph.StationsOnLine(testCase.line))
+		})
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	graph := newTestGraph(t)
+
+	testCases := []struct {
+		name          string
+		from, to      string
+		expectedPath  []Segment
+		expectedError error
+	}{
+		{
+			name: "ShortestPath() should stay on the line it is on where lines run side by side",
+			from: "A", to: "C",
+			expectedPath: []Segment{{Line: "Red", From: "A", To: "B"}, {Line: "Red", From: "B", To: "C"}},
+		},
+		{
+			name: "ShortestPath() should change lines",
+			from: "D", to: "A",
+			expectedPath: []Segment{{Line: "Blue", From: "D", To: "B"}, {Line: "Red", From: "B", To: "A"}},
+		},
+		{
+			name: "ShortestPath() should return no segment from a station to itself",
+			from: "A", to: "A",
+		},
+		{
+			name: "ShortestPath() should fail between stations no line connects",
+			from: "A", to: "E",
+			expectedError: ErrNoPath,
+		},
+		{
+			name: "ShortestPath() should fail on an unknown station",
+			from: "A", to: "Z",
+			expectedError: ErrUnknownStation,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When
+			path, km, err := graph.ShortestPath(testCase.from, testCase.to)
+
+			// Then
+			if testCase.expectedError != nil {
+				assert.ErrorIs(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedPath, path)
+			expectedKm := 0.0
+			for _, segment := range path {
+				expectedKm += graph.length(segment)
+			}
+			assert.InDelta(t, expectedKm, km, 1e-9)
+		})
+	}
+}
+
+func TestAggregateTraffic(t *testing.T) {
+	// Given
+	graph := newTestGraph(t)
+	reports := []TrafficReport{
+		{Station: "B", Condition: TrafficHeavy},
+		{Station: "C", Condition: TrafficLight},
+		{Station: "C", Condition: TrafficHeavy},
+		{Station: "Z", Condition: TrafficHeavy},
+	}
+
+	// When
+	traffic := graph.AggregateTraffic(reports)
+
+	// Then
+	assert.Equal(t, map[string]TrafficCounts{
+		"Red":   {TrafficHeavy: 2, TrafficLight: 1},
+		"Blue":  {TrafficHeavy: 1},
+		"Green": {TrafficHeavy: 2, TrafficLight: 1},
+	}, traffic.Lines)
+	assert.Equal(t, map[Segment]TrafficCounts{
+		{Line: "Red", From: "A", To: "B"}:   {TrafficHeavy: 1},
+		{Line: "Red", From: "B", To: "C"}:   {TrafficHeavy: 2, TrafficLight: 1},
+		{Line: "Blue", From: "B", To: "D"}:  {TrafficHeavy: 1},
+		{Line: "Green", From: "A", To: "B"}: {TrafficHeavy: 1},
+		{Line: "Green", From: "D", To: "C"}: {TrafficHeavy: 1, TrafficLight: 1},
+	}, traffic.Segments)
+	assert.Equal(t, 3, traffic.Lines["Red"].Total())
+}
+
+func TestLoadGraph(t *testing.T) {
+	t.Run("LoadGraph() should read the segments file", func(t *testing.T) {
+		// Given
+		writeTestData(t, "tube-edges.csv", "\"Red\",\"A\",\"B\"\n\"Red\",\"B\"\n{\"line\":\"Blue\"}\n", false)
+		addTestData(t, "tube-stations.csv", "A,51.50,-0.10\nB,51.51,-0.10\n", false)
+
+		// When
+		graph, err := LoadGraph(DefaultStationRepository{}, DefaultSegmentRepository{})
+
+		// Then
+		assert.NoError(t, err)
+		assert.Equal(t, []Segment{{Line: "Red", From: "A", To: "B"}}, graph.Segments())
+	})
+
+	t.Run("GetSegments() should read a JSON segments file", func(t *testing.T) {
+		// Given
+		writeTestData(t, "tube-edges.json", `[{"line": "Red", "from": "A", "to": "B"}]`, false)
+
+		// When
+		segments, err := DefaultSegmentRepository{Mode: Strict}.GetSegments()
+
+		// Then
+		assert.NoError(t, err)
+		assert.Equal(t, []Segment{{Line: "Red", From: "A", To: "B"}}, segments)
+	})
+
+	t.Run("LoadGraph() should return the error of a repository", func(t *testing.T) {
+		// When
+		_, err := LoadGraph(&MockStationRepository{}, &MockSegmentRepository{
+			GetSegmentsFunc: func() ([]Segment, error) { return nil, errTest },
+		})
+
+		// Then
+		assert.ErrorIs(t, err, errTest)
+	})
+}
+
+func TestTubeNetwork(t *testing.T) {
+	// Given the data files of the repository
+	t.Chdir("..")
+
+	// When
+	graph, err := LoadGraph(DefaultStationRepository{Mode: Strict}, DefaultSegmentRepository{Mode: Strict})
+
+	// Then
+	assert.NoError(t, err)
+	victoria := graph.StationsOnLine("Victoria")
+	assert.Len(t, victoria, 16)
+	assert.Equal(t, "Brixton", victoria[0])
+	assert.Equal(t, "Walthamstow Central", victoria[15])
+
+	path, _, err := graph.ShortestPath("Brixton", "Walthamstow Central")
+	assert.NoError(t, err)
+	assert.Equal(t, "Brixton", path[0].From)
+	assert.Equal(t, "Walthamstow Central", path[len(path)-1].To)
+	for i := 1; i < len(path); i++ {
+		assert.Equal(t, path[i-1].To, path[i].From)
+	}
+}
@@ -0,0 +1,241 @@
+package store
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"iter"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TrafficSeverity is how much a report of every traffic condition adds to the cell of a heatmap
+var TrafficSeverity = map[string]float64{
+	TrafficHeavy:    3,
+	TrafficModerate: 2,
+	TrafficLight:    1,
+}
+
+// Colors of the heatmap images
+var (
+	heatmapBackground = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	heatmapStation    = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+)
+
+// Heatmap is a grid over the bounding box of the stations, whose cells accumulate the severity of the traffic
+// reported in them
+type Heatmap struct {
+	// North and West are the latitude and longitude of the north-west corner of the grid
+	North float64
+	West  float64
+	// CellSizeInM is the length of the side of a cell, in metres
+	CellSizeInM float64
+	// Cells holds the severity accumulated in every cell, the first row being the northernmost
+	Cells [][]float64
+	// stations marks the cells holding a station
+	stations [][]bool
+	// cellLatitude and cellLongitude are the side of a cell in degrees
+	cellLatitude  float64
+	cellLongitude float64
+}
+
+// HeatmapFrame is the heatmap of the reports made during a window of time
+type HeatmapFrame struct {
+	Start time.Time
+	End   time.Time
+	*Heatmap
+}
+
+// HeatmapImageOptions defines how a heatmap is drawn
+type HeatmapImageOptions struct {
+	// PixelsPerCell is the side of a cell in pixels, 1 if not set
+	PixelsPerCell int
+	// MaxSeverity is the severity drawn in the hottest color, the highest of the heatmap if not set, so that the
+	// frames of an animation can share a scale
+	MaxSeverity float64
+}
+
+// MaxHeatmapCells is the most cells a heatmap may have, as the whole grid is held in memory and drawn
+const MaxHeatmapCells = 1000000
+
+// NewHeatmap returns an empty heatmap over the bounding box of stations, in cells of given size
+func NewHeatmap(stations []Station, cellSizeInM float64) (*Heatmap, error) {
+	if len(stations) == 0 {
+		return nil, errors.New("no station to draw a heatmap over")
+	}
+	if cellSizeInM <= 0 {
+		return nil, errors.New("cell size must be positive")
+	}
+
+	north, south, west, east := stations[0].Latitude, stations[0].Latitude, stations[0].Longitude, stations[0].Longitude
+	for _, station := range stations {
+		north, south = math.Max(north, station.Latitude), math.Min(south, station.Latitude)
+		west, east = math.Min(west, station.Longitude), math.Max(east, station.Longitude)
+	}
+
+	degreesPerM := 180 / (math.Pi * earthRadiusKm * 1000)
+	h := &Heatmap{
+		North:         north,
+		West:          west,
+		CellSizeInM:   cellSizeInM,
+		cellLatitude:  cellSizeInM * degreesPerM,
+		cellLongitude: cellSizeInM * degreesPerM / math.Cos((north+south)/2*math.Pi/180),
+	}
+	// the size of the grid is checked before it is allocated, in floating point so that it cannot overflow
+	rows, columns := math.Floor((north-south)/h.cellLatitude)+1, math.Floor((east-west)/h.cellLongitude)+1
+	if rows*columns > MaxHeatmapCells {
+		return nil, fmt.Errorf("%.0f cells of %g m exceed the limit of %d, use larger cells", rows*columns, cellSizeInM, MaxHeatmapCells)
+	}
+	h.Cells = make([][]float64, int(rows))
+	h.stations = make([][]bool, int(rows))
+	for row := range h.Cells {
+		h.Cells[row] = make([]float64, int(columns))
+		h.stations[row] = make([]bool, int(columns))
+	}
+	for _, station := range stations {
+		if row, column, ok := h.Cell(station.Latitude, station.Longitude); ok {
+			h.stations[row][column] = true
+		}
+	}
+	return h, nil
+}
+
+// MaxHeatmapFrames is the most frames a heatmap series may have, as every frame is drawn over the whole grid
+const MaxHeatmapFrames = 10000
+
+// HeatmapSeries returns the heatmaps of the reports made during consecutive windows of given length, aligned on
+// multiples of it since the zero time, from the window of the first report to that of the last, so that the frames
+// of an animation are evenly spaced in time. The frames are drawn one at a time on the same grid, so a frame must be
+// used before the next one is drawn. The series may be iterated several times, e.g. to scale the frames alike.
+func HeatmapSeries(stations []Station, reports []TrafficReport, cellSizeInM float64, window time.Duration) (iter.Seq[HeatmapFrame], error) {
+	if window <= 0 {
+		return nil, errors.New("window must be positive")
+	}
+	heatmap, err := NewHeatmap(stations, cellSizeInM)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]TrafficReport, len(reports))
+	copy(sorted, reports)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	if len(sorted) > 0 {
+		first, last := sorted[0].Time.Truncate(window), sorted[len(sorted)-1].Time.Truncate(window)
+		if frames := last.Sub(first)/window + 1; frames > MaxHeatmapFrames {
+			return nil, fmt.Errorf("%d frames of %s exceed the limit of %d, use a longer window", frames, window, MaxHeatmapFrames)
+		}
+	}
+
+	return func(yield func(HeatmapFrame) bool) {
+		if len(sorted) == 0 {
+			return
+		}
+		next := 0
+		for start := sorted[0].Time.Truncate(window); next < len(sorted); start = start.Add(window) {
+			heatmap.Reset()
+			for ; next < len(sorted) && sorted[next].Time.Before(start.Add(window)); next++ {
+				heatmap.Add(sorted[next])
+			}
+			if !yield(HeatmapFrame{Start: start, End: start.Add(window), Heatmap: heatmap}) {
+				return
+			}
+		}
+	}, nil
+}
+
+// Reset clears the severity of every cell
+func (h *Heatmap) Reset() {
+	for _, row := range h.Cells {
+		clear(row)
+	}
+}
+
+// Cell returns the row and column of the cell of a location, or false if it is outside the grid
+func (h *Heatmap) Cell(latitude, longitude float64) (int, int, bool) {
+	row := int(math.Floor((h.North - latitude) / h.cellLatitude))
+	column := int(math.Floor((longitude - h.West) / h.cellLongitude))
+	if row < 0 || row >= len(h.Cells) || column < 0 || column >= len(h.Cells[row]) {
+		return 0, 0, false
+	}
+	return row, column, true
+}
+
+// Add adds the severity of a report to the cell of its location, and returns false if it is outside the grid
+func (h *Heatmap) Add(report TrafficReport) bool {
+	row, column, ok := h.Cell(report.Latitude, report.Longitude)
+	if ok {
+		h.Cells[row][column] += TrafficSeverity[report.Condition]
+	}
+	return ok
+}
+
+// MaxSeverity returns the highest severity of the cells
+func (h *Heatmap) MaxSeverity() float64 {
+	highest := 0.0
+	for _, row := range h.Cells {
+		for _, severity := range row {
+			highest = math.Max(highest, severity)
+		}
+	}
+	return highest
+}
+
+// WriteCSV writes the severity of every cell as a matrix, a line per row from north to south
+func (h *Heatmap) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	for _, row := range h.Cells {
+		line := make([]string, len(row))
+		for column, severity := range row {
+			line[column] = strconv.FormatFloat(severity, 'f', -1, 64)
+		}
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WritePNG draws the heatmap north up, from yellow for the mildest traffic to red for the most severe, over the
+// cells of the stations in grey
+func (h *Heatmap) WritePNG(w io.Writer, options HeatmapImageOptions) error {
+	scale := max(options.PixelsPerCell, 1)
+	highest := options.MaxSeverity
+	if highest <= 0 {
+		highest = h.MaxSeverity()
+	}
+
+	columns := 0
+	if len(h.Cells) > 0 {
+		columns = len(h.Cells[0])
+	}
+	img := image.NewRGBA(image.Rect(0, 0, columns*scale, len(h.Cells)*scale))
+	for row, cells := range h.Cells {
+		for column, severity := range cells {
+			c := heatmapBackground
+			switch {
+			case severity > 0:
+				c = heatColor(math.Min(severity/highest, 1))
+			case h.stations[row][column]:
+				c = heatmapStation
+			}
+			for y := row * scale; y < (row+1)*scale; y++ {
+				for x := column * scale; x < (column+1)*scale; x++ {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// heatColor returns the color of a severity relative to the highest, from yellow near 0 to red at 1
+func heatColor(relative float64) color.RGBA {
+	return color.RGBA{R: 255, G: uint8(math.Round(220 * (1 - relative))), B: 0, A: 255}
+}
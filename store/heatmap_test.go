@@ -0,0 +1,113 @@
+package store
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// heatmapStations span about 2.2 km north to south and 1.4 km west to east
+var heatmapStations = []Station{
+	{Name: "North West", Latitude: 51.52, Longitude: -0.12},
+	{Name: "South East", Latitude: 51.50, Longitude: -0.10},
+}
+
+func TestNewHeatmap(t *testing.T) {
+	testCases := []struct {
+		name            string
+		stations        []Station
+		cellSizeInM     float64
+		expectedRows    int
+		expectedColumns int
+		expectedError   string
+	}{
+		{name: "NewHeatmap() should cover the stations with cells", stations: heatmapStations, cellSizeInM: 500, expectedRows: 5, expectedColumns: 3},
+		{name: "NewHeatmap() should have a cell for a single station", stations: heatmapStations[:1], cellSizeInM: 500, expectedRows: 1, expectedColumns: 1},
+		{name: "NewHeatmap() should fail without stations", cellSizeInM: 500, expectedError: "no station"},
+		{name: "NewHeatmap() should fail on a cell size that is not positive", stations: heatmapStations, expectedError: "cell size must be positive"},
+		{name: "NewHeatmap() should fail on a grid of more cells than the limit", stations: heatmapStations, cellSizeInM: 1, expectedError: "exceed the limit of 1000000"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			heatmap, err := NewHeatmap(testCase.stations, testCase.cellSizeInM)
+
+			if testCase.expectedError != "" {
+				assert.ErrorContains(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, heatmap.Cells, testCase.expectedRows)
+			assert.Len(t, heatmap.Cells[0], testCase.expectedColumns)
+		})
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given
+	heatmap, err := NewHeatmap(heatmapStations, 500)
+	assert.NoError(err)
+
+	// When
+	assert.True(heatmap.Add(TrafficReport{Station: "North West", Latitude: 51.52, Longitude: -0.12, Condition: TrafficHeavy}))
+	assert.True(heatmap.Add(TrafficReport{Station: "North West", Latitude: 51.52, Longitude: -0.12, Condition: TrafficLight}))
+	assert.True(heatmap.Add(TrafficReport{Station: "South East", Latitude: 51.50, Longitude: -0.10, Condition: TrafficModerate}))
+	assert.False(heatmap.Add(TrafficReport{Latitude: 51.6, Longitude: -0.12, Condition: TrafficHeavy}))
+
+	// Then
+	assert.Equal(4.0, heatmap.Cells[0][0])
+	assert.Equal(2.0, heatmap.Cells[4][2])
+	assert.Equal(4.0, heatmap.MaxSeverity())
+
+	var buffer bytes.Buffer
+	assert.NoError(heatmap.WriteCSV(&buffer))
+	assert.Equal("4,0,0\n0,0,0\n0,0,0\n0,0,0\n0,0,2\n", buffer.String())
+
+	buffer.Reset()
+	assert.NoError(heatmap.WritePNG(&buffer, HeatmapImageOptions{PixelsPerCell: 10}))
+	img, err := png.Decode(&buffer)
+	assert.NoError(err)
+	assert.Equal(30, img.Bounds().Dx())
+	assert.Equal(50, img.Bounds().Dy())
+	assert.Equal(heatColor(1), img.At(5, 5))
+	assert.Equal(heatColor(0.5), img.At(25, 45))
+	assert.Equal(heatmapBackground, img.At(15, 25))
+}
+
+func TestHeatmapSeries(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2011, 3, 22, 7, 58, 0, 0, time.UTC)
+
+	// Given reports made at 07:58, 08:01 and 08:12
+	reports := []TrafficReport{
+		{Latitude: 51.52, Longitude: -0.12, Time: start, Condition: TrafficHeavy},
+		{Latitude: 51.52, Longitude: -0.12, Time: start.Add(3 * time.Minute), Condition: TrafficLight},
+		{Latitude: 51.50, Longitude: -0.10, Time: start.Add(14 * time.Minute), Condition: TrafficModerate},
+	}
+
+	// When
+	frames, err := HeatmapSeries(heatmapStations, reports, 500, 5*time.Minute)
+
+	// Then a frame should cover every 5 minutes from 07:55 to 08:15, even without reports
+	assert.NoError(err)
+	var starts []string
+	var severities []float64
+	for frame := range frames {
+		starts = append(starts, frame.Start.Format(time.TimeOnly))
+		severities = append(severities, frame.MaxSeverity())
+	}
+	assert.Equal([]string{"07:55:00", "08:00:00", "08:05:00", "08:10:00"}, starts)
+	assert.Equal([]float64{3, 1, 0, 2}, severities)
+
+	_, err = HeatmapSeries(heatmapStations, reports, 500, 0)
+	assert.ErrorContains(err, "window must be positive")
+
+	// Then a window too short for the time the reports span should be refused rather than exhaust memory
+	_, err = HeatmapSeries(heatmapStations, reports, 500, time.Millisecond)
+	assert.ErrorContains(err, "exceed the limit of 10000")
+}
@@ -1,16 +1,57 @@
 package store
 
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
 // StationRepository defines methods for accessing station data
 type StationRepository interface {
 	GetStations() ([]Station, error)
 }
 
 // DefaultStationRepository implements StationRepository using file-based storage
-type DefaultStationRepository struct{}
+type DefaultStationRepository struct {
+	// Mode defines how lines that cannot be parsed are handled, they are skipped with a warning by default
+	Mode ParseMode
+	// JSONFields maps the fields of stations to their names in a JSON stations file, DefaultJSONFields if not set
+	JSONFields JSONFields
+}
 
 // GetStations returns a slice of all tube stations
 func (r DefaultStationRepository) GetStations() ([]Station, error) {
-	return Stations()
+	stations, err := collect(streamStations(r.JSONFields), r.Mode)
+	if err = warnSkipped(err); err != nil {
+		return []Station{}, err
+	}
+	return stations, nil
+}
+
+// SegmentRepository defines methods for accessing the segments linking stations
+type SegmentRepository interface {
+	GetSegments() ([]Segment, error)
+}
+
+// DefaultSegmentRepository implements SegmentRepository using file-based storage. The segments are read from
+// data/tube-edges.csv, one line,from,to row per pair of adjacent stations, or from a JSON file.
+type DefaultSegmentRepository struct {
+	// Mode defines how lines that cannot be parsed are handled, they are skipped with a warning by default
+	Mode ParseMode
+	// JSONFields maps the fields of segments to their names in a JSON segments file, DefaultJSONFields if not set
+	JSONFields JSONFields
+}
+
+// GetSegments returns a slice of all segments
+func (r DefaultSegmentRepository) GetSegments() ([]Segment, error) {
+	segments, err := collect(streamSegments(r.JSONFields), r.Mode)
+	if err = warnSkipped(err); err != nil {
+		return []Segment{}, err
+	}
+	return segments, nil
 }
 
 // RouteRepository defines methods for accessing route data
@@ -18,10 +59,64 @@ type RouteRepository interface {
 	GetRoute(id int) ([]Location, error)
 }
 
-// DefaultRouteRepository implements RouteRepository using file-based storage
-type DefaultRouteRepository struct{}
+// DefaultRouteRepository implements RouteRepository using file-based storage. The route of a drone is read from
+// data/<id>.csv, .json, .ndjson, .jsonl, .gpx or .nmea, gzip-compressed or not, so that formats can be mixed in one
+// data directory. The format of .csv files is sniffed from their content.
+type DefaultRouteRepository struct {
+	// Mode defines how lines that cannot be parsed are handled, they are skipped with a warning by default
+	Mode ParseMode
+	// Cleaner validates and cleans every route read, if set
+	Cleaner *RouteCleaner
+	// TimeFormat defines how timestamps are parsed, in UTC by default
+	TimeFormat TimeFormat
+	// JSONFields maps the fields of locations to their names in JSON route files, DefaultJSONFields if not set
+	JSONFields JSONFields
+	// Follow keeps streaming the lines appended to a CSV route file, like tail -f, flying them as they are written.
+	// Cleaning needs the whole route, so followed routes are not cleaned.
+	Follow bool
+	// IdleTimeout ends a followed route when no line was appended to it for this long, never if 0
+	IdleTimeout time.Duration
+}
 
-// GetRoute returns a slice of locations from a route file
+// GetRoute returns a slice of locations from a route file, once it is idle if it is followed
 func (r DefaultRouteRepository) GetRoute(id int) ([]Location, error) {
-	return Route(id)
+	if r.Follow {
+		return loadRoute(id, r.followRoute(id), r.Mode, nil)
+	}
+	return loadRoute(id, r.TimeFormat.streamRoute(id, r.JSONFields), r.Mode, r.Cleaner)
+}
+
+// ParseRoute returns the route of a drone with given ID as it is in its file, without cleaning it, handling the lines
+// that cannot be parsed according to r.Mode: in Lenient mode, their diagnostics are returned as ParseErrors
+func (r DefaultRouteRepository) ParseRoute(id int) ([]Location, error) {
+	return collect(r.TimeFormat.streamRoute(id, r.JSONFields), r.Mode)
+}
+
+// loadRoute collects the locations of a route, handling those that cannot be parsed according to mode, and cleans
+// the route if cleaner is set
+func loadRoute(id int, seq iter.Seq2[Location, error], mode ParseMode, cleaner *RouteCleaner) ([]Location, error) {
+	route, err := collect(seq, mode)
+	if err = warnSkipped(err); err != nil {
+		return []Location{}, err
+	}
+	if cleaner == nil {
+		return route, nil
+	}
+
+	route, report := cleaner.Clean(route)
+	logCleaningReport(id, report)
+	return route, nil
+}
+
+// warnSkipped logs the diagnostics of the lines skipped in Lenient mode, and returns any other error
+func warnSkipped(err error) error {
+	var diagnostics ParseErrors
+	if !errors.As(err, &diagnostics) {
+		return err
+	}
+
+	for _, diagnostic := range diagnostics {
+		logrus.Warn(fmt.Sprintf("Skipped line: %s", diagnostic))
+	}
+	return nil
 }
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"strings"
+)
+
+// JSONFields maps the fields of locations, stations and segments, named as in diagnostics, e.g. "latitude" or "lat", to the
+// names they may have in JSON objects, tried in order
+type JSONFields map[string][]string
+
+// DefaultJSONFields are the names tried for the fields missing from a JSONFields
+var DefaultJSONFields = JSONFields{
+	"drone-id":  {"drone", "drone_id", "droneId", "id"},
+	"latitude":  {"latitude", "lat"},
+	"longitude": {"longitude", "lon", "lng"},
+	"time":      {"time", "timestamp"},
+	"station":   {"station", "name"},
+	"lat":       {"lat", "latitude"},
+	"lon":       {"lon", "longitude", "lng"},
+	"lines":     {"lines", "line"},
+	"zone":      {"zone", "fare_zone", "fareZone"},
+	"network":   {"network"},
+	"step-free": {"step_free", "stepFree", "step-free", "stepfree"},
+	"line":      {"line"},
+	"from":      {"from"},
+	"to":        {"to"},
+}
+
+// jsonFieldAliases are the fields of stations mapped like the fields of locations they share, so that a mapping of
+// "latitude" also applies to the "lat" of stations
+var jsonFieldAliases = map[string]string{
+	"lat": "latitude",
+	"lon": "longitude",
+}
+
+// ParseJSONFields parses a field-name mapping such as "latitude=lat|y,longitude=lng|x"
+func ParseJSONFields(spec string) (JSONFields, error) {
+	fields := JSONFields{}
+	for _, mapping := range strings.Split(spec, ",") {
+		if strings.TrimSpace(mapping) == "" {
+			continue
+		}
+		field, names, found := strings.Cut(mapping, "=")
+		if !found || strings.TrimSpace(names) == "" {
+			return nil, fmt.Errorf("%q is not a field=name|name mapping", mapping)
+		}
+		for _, name := range strings.Split(names, "|") {
+			fields[strings.TrimSpace(field)] = append(fields[strings.TrimSpace(field)], strings.TrimSpace(name))
+		}
+	}
+	return fields, nil
+}
+
+// row returns the values of the given fields of a JSON object, in order, falling back to defaults for the
+// missing ones
+func (f JSONFields) row(object map[string]json.RawMessage, fields []string, defaults map[string]string) ([]string, *ParseError) {
+	row := make([]string, len(fields))
+	for i, field := range fields {
+		names := f.names(field)
+		value, found := "", false
+		for _, name := range names {
+			if raw, ok := object[name]; ok && string(raw) != "null" {
+				value, found = jsonText(raw), true
+				break
+			}
+		}
+		if !found {
+			if value, found = defaults[field]; !found {
+				return nil, &ParseError{Field: field, Reason: fmt.Sprintf("missing field, expected one of %s", strings.Join(names, ", "))}
+			}
+		}
+		row[i] = value
+	}
+	return row, nil
+}
+
+// names returns the names a field may have in JSON objects, tried in order
+func (f JSONFields) names(field string) []string {
+	if names, ok := f[field]; ok {
+		return names
+	}
+	if names, ok := f[jsonFieldAliases[field]]; ok {
+		return names
+	}
+	return DefaultJSONFields[field]
+}
+
+// jsonText returns a JSON string unquoted, and any other value as it is written
+func jsonText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	return string(raw)
+}
+
+// scanJSON lazily parses the objects of a JSON array, or of newline-delimited JSON, by turning each into a row
+// parsed like a CSV row. An object that cannot be parsed yields a *ParseError located on the line it ends on, and
+// scanning goes on. Malformed JSON ends the sequence.
+func scanJSON[T any](r io.Reader, path string, toRow func(object map[string]json.RawMessage) ([]string, *ParseError), parse func(row []string) (*T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		buffered := bufio.NewReader(r)
+		lines := &lineCounter{r: buffered}
+		decoder := json.NewDecoder(lines)
+		fail := func(err error) {
+			// a syntax error is located in the input, other errors happen at the end of what was read
+			offset := int64(math.MaxInt64)
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				offset = syntaxErr.Offset
+			}
+			yield(zero, fmt.Errorf("%s:%d: %w", path, lines.lineAt(offset), err))
+		}
+
+		array := false
+		if firstByte(buffered) == '[' {
+			if _, err := decoder.Token(); err != nil {
+				fail(err)
+				return
+			}
+			array = true
+		}
+
+		for array && decoder.More() || !array {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err == io.EOF {
+				return
+			} else if err != nil {
+				fail(err)
+				return
+			}
+			// counting the lines as the decoder goes keeps only the input it has not decoded yet in memory
+			line := lines.lineAt(decoder.InputOffset())
+
+			var object map[string]json.RawMessage
+			var parseErr *ParseError
+			if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+				parseErr = &ParseError{Reason: fmt.Sprintf("%s is not an object", truncate(string(raw), 20))}
+			} else if row, err := toRow(object); err != nil {
+				parseErr = err
+			} else if item, err := parse(row); err != nil {
+				if !errors.As(err, &parseErr) {
+					parseErr = &ParseError{Reason: err.Error()}
+				}
+				// the column of a field in the row means nothing in JSON, its name is enough
+				parseErr.Column = 0
+			} else {
+				if !yield(*item, nil) {
+					return
+				}
+				continue
+			}
+
+			parseErr.File, parseErr.Line = path, line
+			if !yield(zero, parseErr) {
+				return
+			}
+		}
+	}
+}
+
+func truncate(text string, length int) string {
+	if len(text) <= length {
+		return text
+	}
+	return text[:length] + "..."
+}
+
+// lineCounter reads from a reader, keeping the bytes not yet counted to find the line of an offset in the input
+type lineCounter struct {
+	r       io.Reader
+	pending []byte
+	counted int64
+	line    int
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.pending = append(c.pending, p[:n]...)
+	return n, err
+}
+
+// lineAt returns the line of an offset of the input, counting from 1. Offsets must not decrease.
+func (c *lineCounter) lineAt(offset int64) int {
+	consumed := offset - c.counted
+	if consumed > int64(len(c.pending)) {
+		consumed = int64(len(c.pending))
+	}
+	c.line += bytes.Count(c.pending[:consumed], []byte("\n"))
+	c.pending = append(c.pending[:0], c.pending[consumed:]...)
+	c.counted += consumed
+	return c.line + 1
+}
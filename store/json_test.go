@@ -0,0 +1,151 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanJSON(t *testing.T) {
+	testCases := []struct {
+		name           string
+		fields         JSONFields
+		input          string
+		expectedOutput []Location
+		expectedErrors []string
+	}{
+		{
+			name: "scanJSON() should read an array of objects with the default field names",
+			input: `[
+  {"drone": 1234, "lat": 51.474579, "lng": -0.171834, "time": "2011-03-22 07:47:55"},
+  {"latitude": "51.478935", "longitude": "-0.172237", "timestamp": "2011-03-22T07:48:07Z"}
+]`,
+			expectedOutput: []Location{
+				{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:55Z")},
+				{DroneID: 42, Latitude: 51.478935, Longitude: -0.172237, Time: convertToTimeForTests("2011-03-22T07:48:07Z")},
+			},
+		},
+		{
+			name:   "scanJSON() should read newline-delimited objects with mapped field names",
+			fields: JSONFields{"latitude": {"y"}, "longitude": {"x"}},
+			input: `{"y": 51.474579, "x": -0.171834, "time": "2011-03-22 07:47:55"}
+{"y": 51.478935, "x": -0.172237, "time": "2011-03-22 07:48:07"}
+`,
+			expectedOutput: []Location{
+				{DroneID: 42, Latitude: 51.474579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:55Z")},
+				{DroneID: 42, Latitude: 51.478935, Longitude: -0.172237, Time: convertToTimeForTests("2011-03-22T07:48:07Z")},
+			},
+		},
+		{
+			name: "scanJSON() should skip invalid objects with a diagnostic on their line",
+			input: `{"lat": "hello", "lon": -0.171834, "time": "2011-03-22 07:47:55"}
+{"lat": 51.474579, "time": "2011-03-22 07:47:55"}
+[1, 2]
+{"lat": 51.474579, "lon": -0.171834, "time": "2011-03-22 07:47:55"}
+{"lat": 51.474579,
+}
+`,
+			expectedOutput: []Location{
+				{DroneID: 42, Latitude: 51.474579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:55Z")},
+			},
+			expectedErrors: []string{
+				`test.json:1: latitude: "hello" is not a number`,
+				`test.json:2: longitude: missing field, expected one of longitude, lon, lng`,
+				`test.json:3: [1, 2] is not an object`,
+				`test.json:6: invalid character '}' looking for beginning of object key string`,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var output []Location
+			var errors []string
+			defaults := map[string]string{locationFields[0]: "42"}
+			toRow := func(object map[string]json.RawMessage) ([]string, *ParseError) {
+				return testCase.fields.row(object, locationFields, defaults)
+			}
+
+			// When
+			for location, err := range scanJSON(strings.NewReader(testCase.input), "test.json", toRow, TimeFormat{}.parseLocation) {
+				if err != nil {
+					errors = append(errors, err.Error())
+					continue
+				}
+				output = append(output, location)
+			}
+
+			// Then
+			assert.Equal(t, testCase.expectedOutput, output)
+			assert.Equal(t, testCase.expectedErrors, errors)
+		})
+	}
+}
+
+func TestLineCounter(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a long newline-delimited JSON input read through a line counter
+	input := strings.Repeat(`{"lat": 51.474579, "lon": -0.171834, "time": "2011-03-22 07:47:55"}`+"\n", 10000)
+	lines := &lineCounter{r: strings.NewReader(input)}
+	decoder := json.NewDecoder(lines)
+
+	// When its objects are decoded, counting the lines as the decoder goes
+	line := 0
+	for decoder.More() {
+		var raw json.RawMessage
+		assert.NoError(decoder.Decode(&raw))
+		line = lines.lineAt(decoder.InputOffset())
+		// Then only the input not decoded yet should be kept
+		assert.LessOrEqual(len(lines.pending), 64*1024)
+	}
+	assert.Equal(10000, line)
+}
+
+func TestJSONFields_Stations(t *testing.T) {
+	// Given stations whose coordinates are named like the mapped fields of the routes
+	writeTestData(t, "tube-stations.json", `[{"name": "Acton Town", "y": 51.503071, "x": -0.280303}]`, false)
+	fields, err := ParseJSONFields("latitude=y,longitude=x")
+	assert.NoError(t, err)
+
+	// When
+	stations, err := DefaultStationRepository{Mode: Strict, JSONFields: fields}.GetStations()
+
+	// Then the mapping should apply to the stations too
+	assert.NoError(t, err)
+	assert.Equal(t, []Station{{Name: "Acton Town", Latitude: 51.503071, Longitude: -0.280303}}, stations)
+}
+
+func TestParseJSONFields(t *testing.T) {
+	fields, err := ParseJSONFields("latitude=lat|y, longitude=x")
+	assert.NoError(t, err)
+	assert.Equal(t, JSONFields{"latitude": {"lat", "y"}, "longitude": {"x"}}, fields)
+
+	_, err = ParseJSONFields("latitude")
+	assert.Error(t, err)
+}
+
+func TestDefaultRouteRepository_MixedFormats(t *testing.T) {
+	// Given routes and stations in different formats in one data directory
+	writeTestData(t, "1.csv", `1,"51.474579","-0.171834","2011-03-22 07:47:55"`+"\n", false)
+	addTestData(t, "2.json", `[{"drone": 2, "lat": 51.474579, "lon": -0.171834, "time": "2011-03-22 07:47:55"}]`, false)
+	addTestData(t, "3.ndjson.gz", `{"lat": 51.474579, "lon": -0.171834, "time": "2011-03-22 07:47:55"}`+"\n", true)
+	addTestData(t, "4.csv", `{"lat": 51.474579, "lon": -0.171834, "time": "2011-03-22 07:47:55"}`, false)
+	addTestData(t, "5.gpx", `<gpx><trk><trkseg><trkpt lat="51.474579" lon="-0.171834"><time>2011-03-22T07:47:55Z</time></trkpt></trkseg></trk></gpx>`, false)
+	addTestData(t, "tube-stations.json", `[{"name": "Acton Town", "latitude": 51.503071, "longitude": -0.280303}]`, false)
+
+	// Then every route should be read in its own format
+	for id := 1; id <= 5; id++ {
+		route, err := DefaultRouteRepository{Mode: Strict}.GetRoute(id)
+		assert.NoError(t, err)
+		assert.Equal(t, []Location{
+			{DroneID: id, Latitude: 51.474579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:55Z")},
+		}, route, "route %d", id)
+	}
+
+	stations, err := DefaultStationRepository{Mode: Strict}.GetStations()
+	assert.NoError(t, err)
+	assert.Equal(t, []Station{{Name: "Acton Town", Latitude: 51.503071, Longitude: -0.280303}}, stations)
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	kmlNamespace   = "http://www.opengis.net/kml/2.2"
+	kmlGxNamespace = "http://www.google.com/kml/ext/2.2"
+	// circleVertices is the number of vertices of the polygons drawing circles
+	circleVertices = 36
+)
+
+// kmlConditionColors are the aabbggrr colors of the stations by latest traffic condition, grey if none was reported
+var kmlConditionColors = []struct{ condition, color string }{
+	{TrafficHeavy, "ff0000ff"},
+	{TrafficModerate, "ff00a5ff"},
+	{TrafficLight, "ff00ff00"},
+	{"", "ff888888"},
+}
+
+// KMLOptions defines the optional layers of a KML document
+type KMLOptions struct {
+	// VisibilityRadiusInKm draws a circle of this radius around every station if it is positive
+	VisibilityRadiusInKm float64
+}
+
+// WriteKML writes routes as gx:Track placemarks, animated by the time slider of Google Earth, and stations as
+// placemarks styled by the latest traffic condition reported at them
+func WriteKML(w io.Writer, routes [][]Location, stations []Station, reports []TrafficReport, options KMLOptions) error {
+	document := kmlDocument{Name: "Drone simulation"}
+	for _, style := range kmlConditionColors {
+		document.Styles = append(document.Styles, kmlStyle{
+			ID:        conditionStyle(style.condition),
+			IconStyle: &kmlIconStyle{Color: style.color, Icon: kmlIcon{Href: "http://maps.google.com/mapfiles/kml/shapes/placemark_circle.png"}},
+		})
+	}
+	document.Styles = append(document.Styles, kmlStyle{ID: "visibility", LineStyle: &kmlLineStyle{Color: "ff888888", Width: 1}, PolyStyle: &kmlPolyStyle{Color: "33888888"}})
+
+	drones := kmlFolder{Name: "Drones"}
+	for _, route := range routes {
+		placemark := kmlPlacemark{Track: &kmlTrack{}}
+		if len(route) > 0 {
+			placemark.Name = fmt.Sprintf("Drone %d", route[0].DroneID)
+		}
+		for _, location := range route {
+			placemark.Track.When = append(placemark.Track.When, location.Time.UTC().Format(time.RFC3339Nano))
+			placemark.Track.Coords = append(placemark.Track.Coords, fmt.Sprintf("%s %s 0", formatDegrees(location.Longitude), formatDegrees(location.Latitude)))
+		}
+		drones.Placemarks = append(drones.Placemarks, placemark)
+	}
+
+	latest := LatestConditions(reports)
+	stationsFolder := kmlFolder{Name: "Stations"}
+	visibility := kmlFolder{Name: "Visibility"}
+	for _, station := range stations {
+		condition := latest[station.Name]
+		placemark := kmlPlacemark{
+			Name:     station.Name,
+			StyleURL: "#" + conditionStyle(condition),
+			Point:    &kmlPoint{Coordinates: kmlCoordinates(station.Latitude, station.Longitude)},
+		}
+		if condition != "" {
+			placemark.Description = "Latest traffic: " + condition
+		}
+		stationsFolder.Placemarks = append(stationsFolder.Placemarks, placemark)
+
+		if options.VisibilityRadiusInKm > 0 {
+			visibility.Placemarks = append(visibility.Placemarks, kmlPlacemark{
+				Name:     station.Name,
+				StyleURL: "#visibility",
+				Polygon:  &kmlPolygon{Coordinates: circle(station.Latitude, station.Longitude, options.VisibilityRadiusInKm)},
+			})
+		}
+	}
+
+	document.Folders = []kmlFolder{drones, stationsFolder}
+	if options.VisibilityRadiusInKm > 0 {
+		document.Folders = append(document.Folders, visibility)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(kml{Namespace: kmlNamespace, GxNamespace: kmlGxNamespace, Document: document}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// LatestConditions returns the traffic condition last reported at every station
+func LatestConditions(reports []TrafficReport) map[string]string {
+	conditions := map[string]string{}
+	times := map[string]time.Time{}
+	for _, report := range reports {
+		if last, ok := times[report.Station]; ok && report.Time.Before(last) {
+			continue
+		}
+		conditions[report.Station] = report.Condition
+		times[report.Station] = report.Time
+	}
+	return conditions
+}
+
+func conditionStyle(condition string) string {
+	if condition == "" {
+		return "traffic-unknown"
+	}
+	return "traffic-" + condition
+}
+
+func formatDegrees(degrees float64) string {
+	return strconv.FormatFloat(degrees, 'f', -1, 64)
+}
+
+func kmlCoordinates(latitude, longitude float64) string {
+	return formatDegrees(longitude) + "," + formatDegrees(latitude) + ",0"
+}
+
+// circle returns the coordinates of a closed ring approximating a circle of given radius on the Earth's surface
+func circle(latitude, longitude, radiusInKm float64) string {
+	lat, lon := latitude*math.Pi/180, longitude*math.Pi/180
+	angle := radiusInKm / earthRadiusKm
+
+	var coordinates string
+	for i := 0; i <= circleVertices; i++ {
+		bearing := 2 * math.Pi * float64(i%circleVertices) / circleVertices
+		vertexLat := math.Asin(math.Sin(lat)*math.Cos(angle) + math.Cos(lat)*math.Sin(angle)*math.Cos(bearing))
+		vertexLon := lon + math.Atan2(math.Sin(bearing)*math.Sin(angle)*math.Cos(lat), math.Cos(angle)-math.Sin(lat)*math.Sin(vertexLat))
+		if i > 0 {
+			coordinates += " "
+		}
+		coordinates += kmlCoordinates(math.Round(vertexLat*180/math.Pi*1e6)/1e6, math.Round(vertexLon*180/math.Pi*1e6)/1e6)
+	}
+	return coordinates
+}
+
+type kml struct {
+	XMLName     xml.Name    `xml:"kml"`
+	Namespace   string      `xml:"xmlns,attr"`
+	GxNamespace string      `xml:"xmlns:gx,attr"`
+	Document    kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name    string      `xml:"name"`
+	Styles  []kmlStyle  `xml:"Style"`
+	Folders []kmlFolder `xml:"Folder"`
+}
+
+type kmlStyle struct {
+	ID        string        `xml:"id,attr"`
+	IconStyle *kmlIconStyle `xml:"IconStyle,omitempty"`
+	LineStyle *kmlLineStyle `xml:"LineStyle,omitempty"`
+	PolyStyle *kmlPolyStyle `xml:"PolyStyle,omitempty"`
+}
+
+type kmlIconStyle struct {
+	Color string  `xml:"color"`
+	Icon  kmlIcon `xml:"Icon"`
+}
+
+type kmlIcon struct {
+	Href string `xml:"href"`
+}
+
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+type kmlPolyStyle struct {
+	Color string `xml:"color"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string      `xml:"name"`
+	Description string      `xml:"description,omitempty"`
+	StyleURL    string      `xml:"styleUrl,omitempty"`
+	Point       *kmlPoint   `xml:"Point,omitempty"`
+	Polygon     *kmlPolygon `xml:"Polygon,omitempty"`
+	Track       *kmlTrack   `xml:"gx:Track,omitempty"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPolygon struct {
+	Coordinates string `xml:"outerBoundaryIs>LinearRing>coordinates"`
+}
+
+type kmlTrack struct {
+	When   []string `xml:"when"`
+	Coords []string `xml:"gx:coord"`
+}
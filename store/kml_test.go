@@ -0,0 +1,91 @@
+package store
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteKML(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := []Location{
+		{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: start},
+		{DroneID: 1234, Latitude: 51.478935, Longitude: -0.172237, Time: start.Add(time.Second)},
+	}
+	stations := []Station{
+		{Name: "Acton Town", Latitude: 51.503071, Longitude: -0.280303},
+		{Name: "Aldgate", Latitude: 51.514342, Longitude: -0.075627},
+	}
+	reports := []TrafficReport{
+		{DroneID: 1234, Station: "Acton Town", Time: start.Add(time.Second), Condition: TrafficHeavy},
+		{DroneID: 5678, Station: "Acton Town", Time: start, Condition: TrafficLight},
+	}
+
+	testCases := []struct {
+		name            string
+		options         KMLOptions
+		expectedFolders []string
+	}{
+		{
+			name:            "WriteKML() should write a drone and a station folder",
+			expectedFolders: []string{"Drones", "Stations"},
+		},
+		{
+			name:            "WriteKML() should add a visibility folder when given a radius",
+			options:         KMLOptions{VisibilityRadiusInKm: 0.35},
+			expectedFolders: []string{"Drones", "Stations", "Visibility"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// When
+			var buffer bytes.Buffer
+			assert.NoError(WriteKML(&buffer, [][]Location{route}, stations, reports, testCase.options))
+
+			// Then
+			var document kml
+			assert.NoError(xml.Unmarshal(buffer.Bytes(), &document))
+			var folders []string
+			for _, folder := range document.Document.Folders {
+				folders = append(folders, folder.Name)
+			}
+			assert.Equal(testCase.expectedFolders, folders)
+
+			assert.Contains(buffer.String(), "<gx:Track>")
+			assert.Contains(buffer.String(), "<when>2011-03-22T07:47:56Z</when>")
+			assert.Contains(buffer.String(), "<gx:coord>-0.172237 51.478935 0</gx:coord>")
+
+			placemarks := document.Document.Folders[1].Placemarks
+			assert.Equal("#traffic-HEAVY", placemarks[0].StyleURL)
+			assert.Equal("#traffic-unknown", placemarks[1].StyleURL)
+
+			if testCase.options.VisibilityRadiusInKm > 0 {
+				ring := strings.Fields(document.Document.Folders[2].Placemarks[0].Polygon.Coordinates)
+				assert.Len(ring, circleVertices+1)
+				assert.Equal(ring[0], ring[circleVertices])
+			}
+		})
+	}
+}
+
+func TestLatestConditions(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// Given reports out of order
+	reports := []TrafficReport{
+		{Station: "Acton Town", Time: start.Add(time.Minute), Condition: TrafficHeavy},
+		{Station: "Acton Town", Time: start, Condition: TrafficLight},
+		{Station: "Aldgate", Time: start, Condition: TrafficLight},
+		{Station: "Aldgate", Time: start, Condition: TrafficModerate},
+	}
+
+	// Then the latest one of each station should win, the last one given on a tie
+	assert.Equal(t, map[string]string{"Acton Town": TrafficHeavy, "Aldgate": TrafficModerate}, LatestConditions(reports))
+}
@@ -25,3 +25,16 @@ func (m *MockRouteRepository) GetRoute(id int) ([]Location, error) {
 	}
 	return []Location{}, nil
 }
+
+// MockSegmentRepository is a mock implementation for testing
+type MockSegmentRepository struct {
+	GetSegmentsFunc func() ([]Segment, error)
+}
+
+// GetSegments calls the mock function if set, otherwise returns empty slice
+func (m *MockSegmentRepository) GetSegments() ([]Segment, error) {
+	if m.GetSegmentsFunc != nil {
+		return m.GetSegmentsFunc()
+	}
+	return []Segment{}, nil
+}
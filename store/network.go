@@ -0,0 +1,381 @@
+package store
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultFeedBuffer = 1024
+
+// LiveRouteRepository is implemented by repositories streaming locations as they happen, which the dispatcher
+// must not pace again
+type LiveRouteRepository interface {
+	RouteStreamer
+	Live() bool
+}
+
+// IsLive reports whether the locations of a repository arrive in real time
+func IsLive(repo RouteRepository) bool {
+	live, ok := repo.(LiveRouteRepository)
+	return ok && live.Live()
+}
+
+// NetworkRouteConfig holds configuration for listening to live positions
+type NetworkRouteConfig struct {
+	// Network is "tcp" or "udp"
+	Network string
+	// Address to listen on, e.g. "localhost:7000", a free port is picked for port 0
+	Address string
+	// TimeFormat defines how timestamps are parsed, in UTC by default
+	TimeFormat TimeFormat
+	// JSONFields names the fields of JSON messages, DefaultJSONFields if empty
+	JSONFields JSONFields
+	// IdleTimeout ends the route of a drone when no position arrived for it for this long since its last one,
+	// never if 0
+	IdleTimeout time.Duration
+	// Buffer is the number of positions held for each drone until they are flown, 1024 by default. Over TCP, a
+	// full buffer holds back the connection; over UDP, positions are dropped.
+	Buffer int
+	// Drones are the IDs of the drones flown, whose positions are held from the start until their routes are
+	// streamed. The positions of any other drone are dropped unless its route is being streamed.
+	Drones []int
+}
+
+// NetworkRouteRepository implements RouteRepository streaming the positions received on a local port to the
+// matching drone. Each message is a CSV line, in the format of the route files, or a JSON object with the fields
+// of a location, named as in JSON route files. Over TCP, messages are separated by new lines; over UDP, a datagram holds
+// one or more lines.
+type NetworkRouteRepository struct {
+	config   NetworkRouteConfig
+	listener net.Listener
+	packets  net.PacketConn
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	feeds map[int]chan Location
+	conns map[net.Conn]bool
+}
+
+// NewNetworkRouteRepository starts listening for positions
+func NewNetworkRouteRepository(config NetworkRouteConfig) (*NetworkRouteRepository, error) {
+	if config.Buffer <= 0 {
+		config.Buffer = defaultFeedBuffer
+	}
+	r := &NetworkRouteRepository{
+		config: config,
+		done:   make(chan struct{}),
+		feeds:  map[int]chan Location{},
+		conns:  map[net.Conn]bool{},
+	}
+	for _, id := range config.Drones {
+		r.subscribe(id)
+	}
+
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6":
+		listener, err := net.Listen(config.Network, config.Address)
+		if err != nil {
+			return nil, err
+		}
+		r.listener = listener
+		r.wg.Add(1)
+		go r.accept()
+	case "udp", "udp4", "udp6":
+		packets, err := net.ListenPacket(config.Network, config.Address)
+		if err != nil {
+			return nil, err
+		}
+		r.packets = packets
+		r.wg.Add(1)
+		go r.receive()
+	default:
+		return nil, fmt.Errorf("unknown network %q, expected tcp or udp", config.Network)
+	}
+
+	logrus.WithField("Address", r.Addr()).Info(fmt.Sprintf("Listening for positions over %s", config.Network))
+	return r, nil
+}
+
+// Addr returns the address the repository listens on
+func (r *NetworkRouteRepository) Addr() net.Addr {
+	if r.listener != nil {
+		return r.listener.Addr()
+	}
+	return r.packets.LocalAddr()
+}
+
+// Close stops listening, and ends every route once its buffered positions have been flown
+func (r *NetworkRouteRepository) Close() error {
+	select {
+	case <-r.done:
+		return nil
+	default:
+	}
+	close(r.done)
+
+	var err error
+	if r.listener != nil {
+		err = r.listener.Close()
+		r.mu.Lock()
+		for conn := range r.conns {
+			conn.Close()
+		}
+		r.mu.Unlock()
+	} else {
+		err = r.packets.Close()
+	}
+	r.wg.Wait()
+	return err
+}
+
+// Live reports that positions are flown as they arrive
+func (r *NetworkRouteRepository) Live() bool {
+	return true
+}
+
+// GetRoute returns every position received for a drone, once the repository is closed or the drone idle
+func (r *NetworkRouteRepository) GetRoute(id int) ([]Location, error) {
+	return collect(r.StreamRoute(id), Strict)
+}
+
+// StreamRoute returns the positions of a drone as they arrive from now on, until the repository is closed or the
+// drone idle since its last position, or since the route was streamed if no position arrived
+func (r *NetworkRouteRepository) StreamRoute(id int) iter.Seq2[Location, error] {
+	feed := r.subscribe(id)
+	return func(yield func(Location, error) bool) {
+		for {
+			var idle <-chan time.Time
+			if r.config.IdleTimeout > 0 {
+				idle = time.After(r.config.IdleTimeout)
+			}
+
+			select {
+			case location := <-feed:
+				if !yield(location, nil) {
+					return
+				}
+			case <-idle:
+				logrus.WithField("Drone", id).Info(fmt.Sprintf("No position for %s, ending route", r.config.IdleTimeout))
+				return
+			case <-r.done:
+				for {
+					select {
+					case location := <-feed:
+						if !yield(location, nil) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// subscribe returns the buffer of positions of a drone, created when it is listed in the config or its route is
+// first streamed
+func (r *NetworkRouteRepository) subscribe(id int) chan Location {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	feed, ok := r.feeds[id]
+	if !ok {
+		feed = make(chan Location, r.config.Buffer)
+		r.feeds[id] = feed
+	}
+	return feed
+}
+
+// feed returns the buffer of positions of a drone, if it was subscribed to
+func (r *NetworkRouteRepository) feed(id int) (chan Location, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	feed, ok := r.feeds[id]
+	return feed, ok
+}
+
+func (r *NetworkRouteRepository) accept() {
+	defer r.wg.Done()
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.Errorf("Could not accept connection: %s", err)
+			}
+			return
+		}
+
+		r.mu.Lock()
+		r.conns[conn] = true
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() {
+				r.mu.Lock()
+				delete(r.conns, conn)
+				r.mu.Unlock()
+				conn.Close()
+			}()
+
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				if !r.handle(scanner.Text(), true) {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (r *NetworkRouteRepository) receive() {
+	defer r.wg.Done()
+	buffer := make([]byte, 64*1024)
+	for {
+		n, _, err := r.packets.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.Errorf("Could not receive datagram: %s", err)
+			}
+			return
+		}
+
+		for _, line := range strings.Split(string(buffer[:n]), "\n") {
+			r.handle(line, false)
+		}
+	}
+}
+
+// handle parses a message and hands its position to its drone, waiting for room in its buffer if wait is set and
+// dropping it otherwise. The positions of drones not subscribed to are dropped, so that they neither hold back the
+// connection nor pile up. It returns false once the repository is closed.
+func (r *NetworkRouteRepository) handle(message string, wait bool) bool {
+	if strings.TrimSpace(message) == "" {
+		return true
+	}
+
+	location, err := parseMessage(message, r.config.TimeFormat, r.config.JSONFields)
+	if err != nil {
+		logrus.Warn(fmt.Sprintf("Skipped message %q: %s", message, err))
+		return true
+	}
+
+	feed, ok := r.feed(location.DroneID)
+	if !ok {
+		logrus.WithField("Drone", location.DroneID).Debug("Dropped position of a drone not flown")
+		return true
+	}
+	if !wait {
+		select {
+		case feed <- *location:
+		default:
+			logrus.WithField("Drone", location.DroneID).Warn("Dropped position, too many waiting to be flown")
+		}
+		return true
+	}
+
+	select {
+	case feed <- *location:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// parseMessage parses a position received as a JSON object or a CSV line
+func parseMessage(message string, timeFormat TimeFormat, fields JSONFields) (*Location, error) {
+	message = strings.TrimSpace(message)
+	if strings.HasPrefix(message, "{") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(message), &object); err != nil {
+			return nil, err
+		}
+		row, err := fields.row(object, locationFields, nil)
+		if err != nil {
+			return nil, err
+		}
+		return timeFormat.parseLocation(row)
+	}
+
+	row, err := csv.NewReader(strings.NewReader(message)).Read()
+	if err != nil {
+		return nil, err
+	}
+	return timeFormat.parseLocation(row)
+}
+
+// FeedConfig holds configuration for replaying routes as live positions
+type FeedConfig struct {
+	// Speed is the number of simulated seconds per real second, as fast as possible if not positive
+	Speed float64
+	// TimeFormat defines how timestamps are written, for a NetworkRouteRepository parsing them with the same one
+	TimeFormat TimeFormat
+	// JSON writes each location as a JSON object, its fields named by the first of their names in JSONFields,
+	// instead of a CSV line
+	JSON       bool
+	JSONFields JSONFields
+}
+
+// FeedRoutes writes the locations of routes to w in the order of their timestamps, one message per line, waiting
+// between two locations for their time difference divided by the speed. It replays recorded routes as live
+// positions, e.g. into the socket of a NetworkRouteRepository.
+func FeedRoutes(w io.Writer, routes [][]Location, config FeedConfig) error {
+	var locations []Location
+	for _, route := range routes {
+		locations = append(locations, route...)
+	}
+	sort.SliceStable(locations, func(i, j int) bool { return locations[i].Time.Before(locations[j].Time) })
+
+	for i, location := range locations {
+		if i > 0 && config.Speed > 0 {
+			time.Sleep(time.Duration(float64(location.Time.Sub(locations[i-1].Time)) / config.Speed))
+		}
+
+		if _, err := io.WriteString(w, formatMessage(location, config)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatMessage returns the message of a location, as parsed by parseMessage with the same time format and fields
+func formatMessage(location Location, config FeedConfig) string {
+	values := []string{
+		strconv.Itoa(location.DroneID),
+		strconv.FormatFloat(location.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(location.Longitude, 'f', -1, 64),
+		config.TimeFormat.Format(location.Time),
+	}
+	if !config.JSON {
+		return fmt.Sprintf("%s,%q,%q,%q", values[0], values[1], values[2], values[3])
+	}
+
+	object := map[string]any{}
+	for i, field := range locationFields {
+		name := config.JSONFields.names(field)[0]
+		if field == "time" {
+			object[name] = values[i]
+		} else {
+			object[name] = json.Number(values[i])
+		}
+	}
+	message, _ := json.Marshal(object)
+	return string(message)
+}
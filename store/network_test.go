@@ -0,0 +1,200 @@
+package store
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkRouteRepository(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	routes := [][]Location{
+		{
+			{DroneID: 1, Latitude: 51.474579, Longitude: -0.171834, Time: start},
+			{DroneID: 1, Latitude: 51.478935, Longitude: -0.172237, Time: start.Add(2 * time.Second)},
+		},
+		{
+			{DroneID: 2, Latitude: 51.5, Longitude: -0.1, Time: start.Add(time.Second)},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		network string
+	}{
+		{name: "StreamRoute() should stream the positions received over TCP to their drone", network: "tcp"},
+		{name: "StreamRoute() should stream the positions received over UDP to their drone", network: "udp"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Given a repository listening on a free port for the positions of the first drone, and streaming the
+			// route of the second
+			repo, err := NewNetworkRouteRepository(NetworkRouteConfig{Network: testCase.network, Address: "127.0.0.1:0", Drones: []int{1}})
+			assert.NoError(err)
+			defer repo.Close()
+			assert.True(IsLive(repo))
+			stream := repo.StreamRoute(2)
+
+			// When recorded routes are replayed into the socket, along with invalid and JSON messages, and the
+			// positions of a drone not flown
+			conn, err := net.Dial(testCase.network, repo.Addr().String())
+			assert.NoError(err)
+			defer conn.Close()
+			assert.NoError(FeedRoutes(conn, append(routes, []Location{{DroneID: 3, Latitude: 51.5, Longitude: -0.1, Time: start}}), FeedConfig{}))
+			_, err = conn.Write([]byte("1,\"hello\",\"-0.172237\",\"2011-03-22 07:48:01\"\n"))
+			assert.NoError(err)
+			_, err = conn.Write([]byte(`{"drone": 2, "latitude": 51.6, "longitude": -0.2, "time": "2011-03-22 07:48:05"}` + "\n"))
+			assert.NoError(err)
+
+			// Then each drone should receive its own positions in order
+			var second []Location
+			for location, err := range stream {
+				assert.NoError(err)
+				second = append(second, location)
+				if len(second) == 2 {
+					break
+				}
+			}
+			assert.Equal([]Location{routes[1][0], {DroneID: 2, Latitude: 51.6, Longitude: -0.2, Time: start.Add(10 * time.Second)}}, second)
+
+			assert.NoError(repo.Close())
+			first, err := repo.GetRoute(1)
+			assert.NoError(err)
+			assert.Equal(routes[0], first)
+			// and the positions of the drone not flown should have been dropped
+			third, err := repo.GetRoute(3)
+			assert.NoError(err)
+			assert.Empty(third)
+		})
+	}
+}
+
+func TestNetworkRouteRepository_FeedFormats(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+	start := time.Date(2011, 3, 27, 0, 59, 58, 0, time.UTC)
+	route := []Location{
+		{DroneID: 1, Latitude: 51.474579, Longitude: -0.171834, Time: start},
+		{DroneID: 1, Latitude: 51.478935, Longitude: -0.172237, Time: start.Add(4 * time.Second)},
+	}
+
+	testCases := []struct {
+		name       string
+		timeFormat TimeFormat
+		json       bool
+		jsonFields JSONFields
+	}{
+		{
+			name:       "FeedRoutes() should write the timestamps with the layout and time zone the repository parses",
+			timeFormat: TimeFormat{Layouts: []string{"02/01/2006 15:04:05"}, Location: london},
+		},
+		{
+			name:       "FeedRoutes() should write the timestamps as the epoch unit the repository parses",
+			timeFormat: TimeFormat{Epoch: EpochMillis},
+		},
+		{
+			name:       "FeedRoutes() should name the fields of JSON messages as the repository does",
+			timeFormat: TimeFormat{Epoch: EpochSeconds},
+			json:       true,
+			jsonFields: JSONFields{"drone-id": {"uav"}, "latitude": {"y"}, "longitude": {"x"}, "time": {"at"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Given a repository listening for positions in a format other than the default one
+			repo, err := NewNetworkRouteRepository(NetworkRouteConfig{Network: "tcp", Address: "127.0.0.1:0", TimeFormat: testCase.timeFormat, JSONFields: testCase.jsonFields, Drones: []int{1}})
+			assert.NoError(err)
+			defer repo.Close()
+
+			// When a route is fed into its socket in the same format
+			conn, err := net.Dial("tcp", repo.Addr().String())
+			assert.NoError(err)
+			assert.NoError(FeedRoutes(conn, [][]Location{route}, FeedConfig{TimeFormat: testCase.timeFormat, JSON: testCase.json, JSONFields: testCase.jsonFields}))
+			assert.NoError(conn.Close())
+
+			// Then every location should be received as it was fed
+			assert.Eventually(func() bool {
+				feed, _ := repo.feed(1)
+				return len(feed) == len(route)
+			}, 5*time.Second, time.Millisecond)
+			assert.NoError(repo.Close())
+			received, err := repo.GetRoute(1)
+			assert.NoError(err)
+			assert.Len(received, len(route))
+			for i := range received {
+				assert.True(route[i].Time.Equal(received[i].Time), "time %s received as %s", route[i].Time, received[i].Time)
+				received[i].Time = route[i].Time
+			}
+			assert.Equal(route, received)
+		})
+	}
+}
+
+func TestNetworkRouteRepository_IdleTimeout(t *testing.T) {
+	// Given a repository ending idle routes, listening for the positions of a drone
+	repo, err := NewNetworkRouteRepository(NetworkRouteConfig{Network: "tcp", Address: "127.0.0.1:0", IdleTimeout: 50 * time.Millisecond, Drones: []int{1}})
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	// When a single position arrives
+	conn, err := net.Dial("tcp", repo.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("1,\"51.5\",\"-0.1\",\"2011-03-22 07:48:01\"\n"))
+	assert.NoError(t, err)
+	started := time.Now()
+	route, err := repo.GetRoute(1)
+
+	// Then the route should end after the timeout
+	assert.NoError(t, err)
+	assert.Len(t, route, 1)
+	assert.GreaterOrEqual(t, time.Since(started), 50*time.Millisecond)
+}
+
+func TestNetworkRouteRepository_IdleWithoutPosition(t *testing.T) {
+	// Given a repository ending idle routes, listening for the positions of a drone
+	repo, err := NewNetworkRouteRepository(NetworkRouteConfig{Network: "udp", Address: "127.0.0.1:0", IdleTimeout: 50 * time.Millisecond, Drones: []int{1}})
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	// When no position ever arrives for it
+	ended := make(chan []Location)
+	go func() {
+		route, _ := repo.GetRoute(1)
+		ended <- route
+	}()
+
+	// Then its route should still end, empty
+	select {
+	case route := <-ended:
+		assert.Empty(t, route)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the route of a drone without positions should end once idle")
+	}
+}
+
+func TestFeedRoutes(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+
+	// When routes are fed at 100 times their speed
+	var buffer strings.Builder
+	started := time.Now()
+	err := FeedRoutes(&buffer, [][]Location{
+		{{DroneID: 1, Latitude: 51.5, Longitude: -0.1, Time: start.Add(time.Second)}},
+		{{DroneID: 2, Latitude: 51.4, Longitude: -0.2, Time: start}},
+	}, FeedConfig{Speed: 100})
+
+	// Then they should be merged in time order and paced
+	assert.NoError(t, err)
+	assert.Equal(t, "2,\"51.4\",\"-0.2\",\"2011-03-22T07:47:55Z\"\n1,\"51.5\",\"-0.1\",\"2011-03-22T07:47:56Z\"\n", buffer.String())
+	assert.GreaterOrEqual(t, time.Since(started), 10*time.Millisecond)
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NMEARouteRepository implements RouteRepository reading the GGA and RMC sentences logged by a GPS receiver,
+// data/<id>.nmea or its gzip-compressed version
+type NMEARouteRepository struct {
+	// Mode defines how sentences that cannot be parsed are handled, they are skipped with a warning by default
+	Mode ParseMode
+	// Cleaner validates and cleans every route read, if set
+	Cleaner *RouteCleaner
+}
+
+// GetRoute returns the fixes of an NMEA log as the route of the drone with given ID
+func (r NMEARouteRepository) GetRoute(id int) ([]Location, error) {
+	return loadRoute(id, streamNMEA(id), r.Mode, r.Cleaner)
+}
+
+// StreamRoute streams the fixes of an NMEA log as they are parsed, see DefaultRouteRepository.StreamRoute
+func (r NMEARouteRepository) StreamRoute(id int) iter.Seq2[Location, error] {
+	if r.Cleaner != nil {
+		return streamLoaded(r, id)
+	}
+
+	return skipUnparsed(streamNMEA(id), r.Mode)
+}
+
+// ParseNMEA returns the fixes of an NMEA log as the locations of the drone with given ID, handling the sentences
+// that cannot be parsed according to mode
+func ParseNMEA(r io.Reader, droneID int, mode ParseMode) ([]Location, error) {
+	return collect(scanNMEA(r, "", droneID), mode)
+}
+
+// streamNMEA lazily parses the NMEA log of a drone, see scanNMEA
+func streamNMEA(id int) iter.Seq2[Location, error] {
+	return func(yield func(Location, error) bool) {
+		file, path, err := openData(strconv.Itoa(id), ".nmea")
+		if err != nil {
+			yield(Location{}, err)
+			return
+		}
+		defer file.Close()
+
+		for location, err := range scanNMEA(file, path, id) {
+			if !yield(location, err) {
+				return
+			}
+		}
+	}
+}
+
+// scanNMEA lazily parses the GGA and RMC sentences of an NMEA log into locations of the drone with given ID, other
+// sentences are ignored. GGA sentences only have a time of day, so they are dated by the last RMC sentence, and a
+// fix reported by both sentences of the same epoch is only yielded once. A sentence that cannot be parsed yields a
+// *ParseError and scanning goes on, any other error ends the sequence.
+func scanNMEA(r io.Reader, path string, droneID int) iter.Seq2[Location, error] {
+	return func(yield func(Location, error) bool) {
+		scanner := bufio.NewScanner(r)
+		var date, last time.Time
+		for line := 1; scanner.Scan(); line++ {
+			sentence := strings.TrimSpace(scanner.Text())
+			if sentence == "" {
+				continue
+			}
+
+			location, ok, err := parseNMEASentence(sentence, &date, last)
+			if err != nil {
+				err.File, err.Line = path, line
+				if !yield(Location{}, err) {
+					return
+				}
+				continue
+			}
+			if !ok || location.Time.Equal(last) {
+				continue
+			}
+
+			last = location.Time
+			location.DroneID = droneID
+			if !yield(location, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(Location{}, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+}
+
+// parseNMEASentence returns the fix of a GGA or RMC sentence, or false for other sentences. RMC sentences update
+// the date, which GGA sentences are dated with, rolling over to the next day after the last fix if needed.
+func parseNMEASentence(sentence string, date *time.Time, last time.Time) (Location, bool, *ParseError) {
+	fields, err := checkNMEASentence(sentence)
+	if err != nil {
+		return Location{}, false, err
+	}
+	if len(fields[0]) != 5 {
+		return Location{}, false, nil
+	}
+
+	switch fields[0][2:] {
+	case "RMC":
+		if err := requireFields(fields, "sentence", "time", "status", "latitude", "N/S", "longitude", "E/W", "speed", "course", "date"); err != nil {
+			return Location{}, false, err
+		}
+		if fields[2] != "A" {
+			return Location{}, false, fieldError(3, "status", "no fix")
+		}
+		day, err := time.Parse("020106", fields[9])
+		if err != nil {
+			return Location{}, false, fieldError(10, "date", fmt.Sprintf("%q is not a ddmmyy date", fields[9]))
+		}
+		*date = day
+
+		return parseNMEAFix(fields, 1, 3, day, time.Time{})
+	case "GGA":
+		if err := requireFields(fields, "sentence", "time", "latitude", "N/S", "longitude", "E/W", "quality"); err != nil {
+			return Location{}, false, err
+		}
+		if fields[6] == "" || fields[6] == "0" {
+			return Location{}, false, fieldError(7, "quality", "no fix")
+		}
+		if date.IsZero() {
+			return Location{}, false, &ParseError{Reason: "GGA sentence before any RMC sentence, its date is unknown"}
+		}
+
+		return parseNMEAFix(fields, 1, 2, *date, last)
+	default:
+		return Location{}, false, nil
+	}
+}
+
+// checkNMEASentence verifies the checksum of a sentence and returns its fields, starting with its talker and type
+func checkNMEASentence(sentence string) ([]string, *ParseError) {
+	if !strings.HasPrefix(sentence, "$") {
+		return nil, &ParseError{Reason: "sentence does not start with $"}
+	}
+	body, checksum, found := strings.Cut(sentence[1:], "*")
+	if !found {
+		return nil, &ParseError{Reason: "missing checksum"}
+	}
+
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	expected, err := strconv.ParseUint(checksum, 16, 8)
+	if err != nil {
+		return nil, &ParseError{Reason: fmt.Sprintf("%q is not a checksum", checksum)}
+	}
+	if byte(expected) != sum {
+		return nil, &ParseError{Reason: fmt.Sprintf("checksum %02X, expected %02X", expected, sum)}
+	}
+	return strings.Split(body, ","), nil
+}
+
+// parseNMEAFix returns the fix of a sentence given the columns of its time and latitude, the longitude following
+// the latitude's hemisphere. The fix is dated with day, and moved to the next day if it would be before last.
+func parseNMEAFix(fields []string, timeColumn, latitudeColumn int, day, last time.Time) (Location, bool, *ParseError) {
+	timeOfDay, err := time.Parse("150405.999", fields[timeColumn])
+	if err != nil {
+		return Location{}, false, fieldError(timeColumn+1, "time", fmt.Sprintf("%q is not a hhmmss time", fields[timeColumn]))
+	}
+	fix := time.Date(day.Year(), day.Month(), day.Day(), timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(),
+		timeOfDay.Nanosecond(), time.UTC)
+	if !last.IsZero() && last.Sub(fix) > 12*time.Hour {
+		fix = fix.AddDate(0, 0, 1)
+	}
+
+	latitude, perr := parseNMEACoordinate(fields, latitudeColumn, "latitude", 2, "N", "S", 90)
+	if perr != nil {
+		return Location{}, false, perr
+	}
+	longitude, perr := parseNMEACoordinate(fields, latitudeColumn+2, "longitude", 3, "E", "W", 180)
+	if perr != nil {
+		return Location{}, false, perr
+	}
+
+	return Location{Latitude: latitude, Longitude: longitude, Time: fix}, true, nil
+}
+
+// parseNMEACoordinate parses a coordinate in degrees and decimal minutes, e.g. 5128.4763 for 51° 28.4763', followed
+// by its hemisphere in the next column
+func parseNMEACoordinate(fields []string, column int, field string, degreeDigits int, positive, negative string, limit float64) (float64, *ParseError) {
+	value := fields[column]
+	if len(value) < degreeDigits+2 {
+		return 0, fieldError(column+1, field, fmt.Sprintf("%q is not in degrees and minutes", value))
+	}
+	degrees, err := strconv.ParseFloat(value[:degreeDigits], 64)
+	if err != nil {
+		return 0, fieldError(column+1, field, fmt.Sprintf("%q is not in degrees and minutes", value))
+	}
+	minutes, err := strconv.ParseFloat(value[degreeDigits:], 64)
+	if err != nil || minutes >= 60 {
+		return 0, fieldError(column+1, field, fmt.Sprintf("%q is not in degrees and minutes", value))
+	}
+
+	coordinate := degrees + minutes/60
+	switch fields[column+1] {
+	case positive:
+	case negative:
+		coordinate = -coordinate
+	default:
+		return 0, fieldError(column+2, field+" hemisphere", fmt.Sprintf("%q is neither %s nor %s", fields[column+1], positive, negative))
+	}
+	if coordinate < -limit || coordinate > limit {
+		return 0, fieldError(column+1, field, fmt.Sprintf("%s is not between -%g and %g", value, limit, limit))
+	}
+	return coordinate, nil
+}
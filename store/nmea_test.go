@@ -0,0 +1,108 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nmeaSentence returns a sentence with its checksum
+func nmeaSentence(body string) string {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return fmt.Sprintf("$%s*%02X", body, sum)
+}
+
+func TestParseNMEA(t *testing.T) {
+	testCases := []struct {
+		name           string
+		sentences      []string
+		expectedOutput []Location
+		expectedErrors []string
+	}{
+		{
+			name: "ParseNMEA() should read one fix per epoch from RMC and GGA sentences",
+			sentences: []string{
+				nmeaSentence("GPRMC,074755,A,5128.4747,N,00010.3100,W,12.5,90.0,220311,,"),
+				nmeaSentence("GPGGA,074755,5128.4747,N,00010.3100,W,1,08,0.9,30.0,M,47.0,M,,"),
+				nmeaSentence("GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1"),
+				nmeaSentence("GNGGA,074801.5,5128.7361,N,00010.3342,W,1,08,0.9,30.0,M,47.0,M,,"),
+			},
+			expectedOutput: []Location{
+				{DroneID: 1234, Latitude: 51.474578333333334, Longitude: -0.17183333333333334, Time: time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)},
+				{DroneID: 1234, Latitude: 51.478935, Longitude: -0.17223666666666665, Time: time.Date(2011, 3, 22, 7, 48, 1, 500000000, time.UTC)},
+			},
+		},
+		{
+			name: "ParseNMEA() should date GGA sentences after midnight with the next day",
+			sentences: []string{
+				nmeaSentence("GPRMC,235959,A,3351.0000,S,15112.0000,E,0.0,0.0,311211,,"),
+				nmeaSentence("GPGGA,000001,3351.0000,S,15112.0000,E,1,08,0.9,30.0,M,47.0,M,,"),
+			},
+			expectedOutput: []Location{
+				{DroneID: 1234, Latitude: -33.85, Longitude: 151.2, Time: time.Date(2011, 12, 31, 23, 59, 59, 0, time.UTC)},
+				{DroneID: 1234, Latitude: -33.85, Longitude: 151.2, Time: time.Date(2012, 1, 1, 0, 0, 1, 0, time.UTC)},
+			},
+		},
+		{
+			name: "ParseNMEA() should skip invalid sentences with a diagnostic",
+			sentences: []string{
+				nmeaSentence("GPGGA,074754,5128.4747,N,00010.3100,W,1,08,0.9,30.0,M,47.0,M,,"),
+				"$GPRMC,074755,A,5128.4747,N,00010.3100,W,12.5,90.0,220311,,*00",
+				"GPRMC,074755,A,5128.4747,N,00010.3100,W,12.5,90.0,220311,,",
+				nmeaSentence("GPRMC,074755,V,,,,,,,220311,,"),
+				nmeaSentence("GPRMC,074756,A,5128.4747,X,00010.3100,W,12.5,90.0,220311,,"),
+				nmeaSentence("GPRMC,074757,A,5128.4747,N,00010.3100,W,12.5,90.0,310211,,"),
+				nmeaSentence("GPGGA,074758,5128.4747,N,00010.3100,W,0,00,,,M,,M,,"),
+			},
+			expectedErrors: []string{
+				":1: GGA sentence before any RMC sentence, its date is unknown",
+				":2: checksum 00, expected 0A",
+				":3: sentence does not start with $",
+				":4: column 3 (status): no fix",
+				":5: column 5 (latitude hemisphere): \"X\" is neither N nor S",
+				":6: column 10 (date): \"310211\" is not a ddmmyy date",
+				":7: column 7 (quality): no fix",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When
+			output, err := ParseNMEA(strings.NewReader(strings.Join(testCase.sentences, "\r\n")), 1234, Lenient)
+
+			// Then
+			assert.Equal(t, testCase.expectedOutput, output)
+			if testCase.expectedErrors == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var diagnostics ParseErrors
+			assert.ErrorAs(t, err, &diagnostics)
+			var messages []string
+			for _, diagnostic := range diagnostics {
+				messages = append(messages, diagnostic.Error())
+			}
+			assert.Equal(t, testCase.expectedErrors, messages)
+		})
+	}
+}
+
+func TestNMEARouteRepository_GetRoute(t *testing.T) {
+	// Given a compressed NMEA log
+	writeTestData(t, "1234.nmea.gz", nmeaSentence("GPRMC,074755,A,5128.4747,N,00010.3100,W,12.5,90.0,220311,,")+"\n", true)
+
+	// When
+	route, err := NMEARouteRepository{Mode: Strict}.GetRoute(1234)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Len(t, route, 1)
+	assert.Equal(t, 1234, route[0].DroneID)
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseMode defines how rows that cannot be parsed are handled
+type ParseMode int
+
+const (
+	// Lenient skips the rows that cannot be parsed, and collects a diagnostic for each of them
+	Lenient ParseMode = iota
+	// Strict fails on the first row that cannot be parsed
+	Strict
+)
+
+// ParseError describes why a row of a data file could not be parsed
+type ParseError struct {
+	File string
+	// Line is the line of the file the row starts on, counting from 1
+	Line int
+	// Column is the position of the field in the row, counting from 1
+	Column int
+	Field  string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	position := e.File
+	if e.Line > 0 {
+		position = fmt.Sprintf("%s:%d", position, e.Line)
+	}
+	if e.Column > 0 {
+		return fmt.Sprintf("%s: column %d (%s): %s", position, e.Column, e.Field, e.Reason)
+	}
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s: %s", position, e.Field, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s", position, e.Reason)
+}
+
+// ParseErrors collects the diagnostics of every row skipped in Lenient mode
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d rows could not be parsed:\n%s", len(e), strings.Join(messages, "\n"))
+}
+
+// Unwrap returns the diagnostics, so that errors.As finds the first *ParseError
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// fieldError returns a diagnostic for a field of a row, to be located in its file by the caller
+func fieldError(column int, field, reason string) *ParseError {
+	return &ParseError{Column: column, Field: field, Reason: reason}
+}
+
+// requireFields returns a diagnostic if a row has fewer fields than named
+func requireFields(row []string, fields ...string) *ParseError {
+	if len(row) < len(fields) {
+		return fieldError(len(row)+1, fields[len(row)], fmt.Sprintf("missing field, expected %d fields but got %d", len(fields), len(row)))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRouteCSV = `1234,"51.474579","-0.171834","2011-03-22 07:47:55"
+1234,"hello","-0.172361","2011-03-22 07:48:01"
+1234,"51.478935"
+
+1234,"51.478935","-0.172237","2011-03-22 07:48:07"
+`
+
+func TestParseCSV(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mode           ParseMode
+		input          string
+		expectedOutput []Location
+		expectedErrors []string
+	}{
+		{
+			name:  "parseCSV() should skip invalid rows and collect a diagnostic for each in Lenient mode",
+			mode:  Lenient,
+			input: testRouteCSV,
+			expectedOutput: []Location{
+				{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:55Z")},
+				{DroneID: 1234, Latitude: 51.478935, Longitude: -0.172237, Time: convertToTimeForTests("2011-03-22T07:48:07Z")},
+			},
+			expectedErrors: []string{
+				`test.csv:2: column 2 (latitude): "hello" is not a number`,
+				`test.csv:3: column 3 (longitude): missing field, expected 4 fields but got 2`,
+			},
+		},
+		{
+			name:           "parseCSV() should fail on the first invalid row in Strict mode",
+			mode:           Strict,
+			input:          testRouteCSV,
+			expectedErrors: []string{`test.csv:2: column 2 (latitude): "hello" is not a number`},
+		},
+		{
+			name:           "parseCSV() should report malformed CSV with its line",
+			mode:           Strict,
+			input:          "1234,\"51.47\"x,\"-0.17\",\"2011-03-22 07:47:55\"\n",
+			expectedErrors: []string{`test.csv:1: extraneous or missing " in quoted-field at character 12`},
+		},
+		{
+			name:  "parseCSV() should return every row of a valid file without error",
+			mode:  Strict,
+			input: "1234,51.474579,-0.171834,2011-03-22 07:47:55\n",
+			expectedOutput: []Location{
+				{DroneID: 1234, Latitude: 51.474579, Longitude: -0.171834, Time: convertToTimeForTests("2011-03-22T07:47:55Z")},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			locations, err := parseCSV(strings.NewReader(testCase.input), "test.csv", testCase.mode, parseLocation)
+
+			assert.Equal(t, testCase.expectedOutput, locations)
+			if len(testCase.expectedErrors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var messages []string
+			var diagnostics ParseErrors
+			var diagnostic *ParseError
+			switch {
+			case errors.As(err, &diagnostics):
+				assert.Equal(t, Lenient, testCase.mode)
+				for _, diagnostic := range diagnostics {
+					messages = append(messages, diagnostic.Error())
+				}
+			case errors.As(err, &diagnostic):
+				assert.Equal(t, Strict, testCase.mode)
+				messages = append(messages, diagnostic.Error())
+			default:
+				t.Fatalf("unexpected error %v", err)
+			}
+			assert.Equal(t, testCase.expectedErrors, messages)
+		})
+	}
+}
+
+func FuzzParseLocation(f *testing.F) {
+	f.Add("1234", "51.474579", "-0.171834", "2011-03-22 07:47:55")
+	f.Add("0", "NaN", "Inf", "")
+	f.Fuzz(func(t *testing.T, droneID, latitude, longitude, time string) {
+		for fields := 0; fields <= 4; fields++ {
+			row := []string{droneID, latitude, longitude, time}[:fields]
+
+			location, err := parseLocation(row)
+			if err != nil {
+				var diagnostic *ParseError
+				assert.ErrorAs(t, err, &diagnostic)
+				assert.Nil(t, location)
+				continue
+			}
+			assert.NotZero(t, location.DroneID)
+			assert.LessOrEqual(t, location.Latitude, 90.0)
+			assert.GreaterOrEqual(t, location.Longitude, -180.0)
+		}
+	})
+}
+
+func FuzzParseStation(f *testing.F) {
+	f.Add("Aldgate", "51.514342", "-0.075627")
+	f.Add("", "-91", "1e400")
+	f.Fuzz(func(t *testing.T, name, latitude, longitude string) {
+		for fields := 0; fields <= 3; fields++ {
+			row := []string{name, latitude, longitude}[:fields]
+
+			station, err := parseStation(row)
+			if err != nil {
+				var diagnostic *ParseError
+				assert.ErrorAs(t, err, &diagnostic)
+				assert.Nil(t, station)
+				continue
+			}
+			assert.NotEmpty(t, strings.TrimSpace(station.Name))
+		}
+	})
+}
+
+func FuzzParseCSV(f *testing.F) {
+	f.Add(testRouteCSV)
+	f.Add("\"unterminated\n1234,1,2,2011-03-22 07:47:55\n")
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, mode := range []ParseMode{Lenient, Strict} {
+			locations, err := parseCSV(strings.NewReader(input), "fuzz.csv", mode, parseLocation)
+			if mode == Strict && err != nil {
+				assert.Nil(t, locations)
+			}
+
+			var diagnostic *ParseError
+			if err != nil && errors.As(err, &diagnostic) {
+				assert.Equal(t, "fuzz.csv", diagnostic.File)
+				assert.Positive(t, diagnostic.Line)
+			}
+		}
+	})
+}
@@ -1,22 +1,150 @@
 package store
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"os"
 )
 
-func read(filename string) ([][]string, error) {
-	file, err := os.Open(fmt.Sprintf("data/%s.csv", filename))
+// gzipMagic starts every gzip-compressed file
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// openData opens the data file with given name and extension, or its gzip-compressed version if there is no plain
+// one. Compressed content is detected from its first bytes and decompressed transparently.
+func openData(filename, extension string) (io.ReadCloser, string, error) {
+	path := fmt.Sprintf("data/%s%s", filename, extension)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		path += ".gz"
+		file, err = os.Open(path)
+	}
 	if err != nil {
-		return nil, err
+		return nil, path, err
 	}
-	defer file.Close()
 
-	lines, err := csv.NewReader(file).ReadAll()
+	reader, err := decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, path, fmt.Errorf("%s: %w", path, err)
+	}
+	return reader, path, nil
+}
+
+// decompress returns a reader of the decompressed content of a gzip-compressed file, or of the file as it is
+func decompress(file *os.File) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if string(magic) != string(gzipMagic) {
+		return readCloser{buffered, file}, nil
+	}
+
+	gzipReader, err := gzip.NewReader(buffered)
 	if err != nil {
 		return nil, err
 	}
+	return readCloser{gzipReader, file}, nil
+}
+
+// readCloser reads from a reader wrapping a file, and closes the file
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// parseCSV parses every row of a CSV file with parse. In Strict mode, it fails with a *ParseError on the first row
+// that cannot be parsed. In Lenient mode, it skips those rows and returns the others along with ParseErrors.
+func parseCSV[T any](r io.Reader, path string, mode ParseMode, parse func(row []string) (*T, error)) ([]T, error) {
+	return collect(scanCSV(r, path, parse), mode)
+}
+
+// scanCSV lazily parses the rows of a CSV file with parse, which skips a row, such as a header, by returning nil
+// without error. A row that cannot be parsed yields a *ParseError and scanning goes on, any other error ends the
+// sequence.
+func scanCSV[T any](r io.Reader, path string, parse func(row []string) (*T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+
+		var zero T
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				return
+			}
+
+			var csvErr *csv.ParseError
+			if err != nil && !errors.As(err, &csvErr) {
+				yield(zero, err)
+				return
+			}
+
+			item, parseErr := parseRow(row, err, parse)
+			if parseErr == nil {
+				if item != nil && !yield(*item, nil) {
+					return
+				}
+				continue
+			}
+			if csvErr != nil {
+				parseErr.Line = csvErr.StartLine
+			} else {
+				parseErr.Line, _ = reader.FieldPos(0)
+			}
+			parseErr.File = path
+
+			if !yield(zero, parseErr) {
+				return
+			}
+		}
+	}
+}
+
+// parseRow parses a row read from a CSV line, returning a diagnostic to be located in its file by the caller
+func parseRow[T any](row []string, rowErr error, parse func(row []string) (*T, error)) (*T, *ParseError) {
+	if rowErr != nil {
+		var csvErr *csv.ParseError
+		if errors.As(rowErr, &csvErr) {
+			return nil, &ParseError{Reason: fmt.Sprintf("%s at character %d", csvErr.Err, csvErr.Column)}
+		}
+		return nil, &ParseError{Reason: rowErr.Error()}
+	}
 
-	return lines, nil
+	item, err := parse(row)
+	if err != nil {
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			parseErr = &ParseError{Reason: err.Error()}
+		}
+		return nil, parseErr
+	}
+	return item, nil
+}
+
+// collect gathers the items of a sequence. In Strict mode, it fails on the first error. In Lenient mode, it
+// skips the items that cannot be parsed and returns the others along with ParseErrors.
+func collect[T any](seq iter.Seq2[T, error], mode ParseMode) ([]T, error) {
+	var items []T
+	var diagnostics ParseErrors
+	for item, err := range seq {
+		var parseErr *ParseError
+		switch {
+		case err == nil:
+			items = append(items, item)
+		case mode == Lenient && errors.As(err, &parseErr):
+			diagnostics = append(diagnostics, parseErr)
+		default:
+			return nil, err
+		}
+	}
+
+	if len(diagnostics) > 0 {
+		return items, diagnostics
+	}
+	return items, nil
 }
@@ -0,0 +1,23 @@
+package store
+
+import "time"
+
+// Traffic conditions a drone can report at a station
+const (
+	TrafficHeavy    = "HEAVY"
+	TrafficLight    = "LIGHT"
+	TrafficModerate = "MODERATE"
+)
+
+// TrafficReport defines the traffic condition reported by a drone at a tube station
+type TrafficReport struct {
+	DroneID   int       `json:"drone"`
+	Station   string    `json:"station"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Time      time.Time `json:"time"`
+	SpeedKph  float64   `json:"speed_kph"`
+	Condition string    `json:"condition"`
+	// Confidence is how reliable the drone deems its assessment, from 0 to 1, 0 if unknown
+	Confidence float64 `json:"confidence,omitempty"`
+}
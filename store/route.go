@@ -1,16 +1,15 @@
 package store
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
-const timeLayout = time.RFC3339
-
 // Location defines the location of a drone at a given time
 type Location struct {
 	DroneID   int
@@ -19,45 +18,64 @@ type Location struct {
 	Time      time.Time
 }
 
-// Route returns the route of a drone with given ID as a slice of Locations
+// Route returns the route of a drone with given ID as a slice of Locations, skipping the lines that cannot be parsed
 func Route(id int) ([]Location, error) {
-	lines, err := read(strconv.Itoa(id))
-	if err != nil {
-		return []Location{}, err
-	}
+	locations, err := RouteWithMode(id, Lenient)
 
-	var locations []Location
-	for _, line := range lines {
-		location, err := parseLocation(line)
-		if err != nil {
-			logrus.Debug(fmt.Sprintf("Could not parse location %s: %s\n", line, err))
-			continue
+	var diagnostics ParseErrors
+	if errors.As(err, &diagnostics) {
+		for _, diagnostic := range diagnostics {
+			logrus.Debug(fmt.Sprintf("Could not parse location: %s", diagnostic))
 		}
-
-		locations = append(locations, *location)
+		return locations, nil
+	}
+	if err != nil {
+		return []Location{}, err
 	}
 
 	return locations, nil
 }
 
+// RouteWithMode returns the route of a drone with given ID, in whichever format its file is, handling the lines that
+// cannot be parsed according to mode
+func RouteWithMode(id int, mode ParseMode) ([]Location, error) {
+	return DefaultRouteRepository{Mode: mode}.ParseRoute(id)
+}
+
+var locationFields = []string{"drone-id", "latitude", "longitude", "time"}
+
 func parseLocation(line []string) (*Location, error) {
-	droneID, err := strconv.Atoi(line[0])
-	if err != nil || droneID == 0 {
+	return TimeFormat{}.parseLocation(line)
+}
+
+func (f TimeFormat) parseLocation(line []string) (*Location, error) {
+	if err := requireFields(line, locationFields...); err != nil {
 		return nil, err
 	}
-	latitude, err := strconv.ParseFloat(line[1], 64)
+
+	droneID, err := strconv.Atoi(line[0])
 	if err != nil {
-		return nil, err
+		return nil, fieldError(1, locationFields[0], fmt.Sprintf("%q is not an integer", line[0]))
+	}
+	if droneID == 0 {
+		return nil, fieldError(1, locationFields[0], "drone ID must not be 0")
 	}
-	longitude, err := strconv.ParseFloat(line[2], 64)
+
+	latitude, err := parseCoordinate(line, 2, locationFields[1], 90)
 	if err != nil {
 		return nil, err
 	}
-	time, err := time.Parse(timeLayout, strings.Replace(line[3], " ", "T", 1)+"Z")
+
+	longitude, err := parseCoordinate(line, 3, locationFields[2], 180)
 	if err != nil {
 		return nil, err
 	}
 
+	time, err := f.Parse(line[3])
+	if err != nil {
+		return nil, fieldError(4, locationFields[3], err.Error())
+	}
+
 	return &Location{
 		DroneID:   droneID,
 		Latitude:  latitude,
@@ -65,3 +83,24 @@ func parseLocation(line []string) (*Location, error) {
 		Time:      time,
 	}, nil
 }
+
+// parseCoordinate parses the degrees in a column of a row, counting from 1, which must not exceed ±limit
+func parseCoordinate(row []string, column int, field string, limit float64) (float64, error) {
+	degrees, reason := parseDegrees(row[column-1], limit)
+	if reason != "" {
+		return 0, fieldError(column, field, reason)
+	}
+	return degrees, nil
+}
+
+// parseDegrees parses degrees which must not exceed ±limit, or returns why they cannot be parsed
+func parseDegrees(value string, limit float64) (float64, string) {
+	degrees, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Sprintf("%q is not a number", value)
+	}
+	if math.IsNaN(degrees) || degrees < -limit || degrees > limit {
+		return 0, fmt.Sprintf("%s is not between -%g and %g", value, limit, limit)
+	}
+	return degrees, ""
+}
@@ -133,6 +133,24 @@ func TestParseLocation(t *testing.T) {
 			expectedOutput: nil,
 			expectedError:  true,
 		},
+		{
+			name:           "parseLocation() should return nil if the input is missing fields",
+			input:          []string{"1234", "51.479015", "-0.172361"},
+			expectedOutput: nil,
+			expectedError:  true,
+		},
+		{
+			name:           "parseLocation() should return nil if the input DroneID is 0",
+			input:          []string{"0", "51.479015", "-0.172361", "2011-03-22 07:48:01"},
+			expectedOutput: nil,
+			expectedError:  true,
+		},
+		{
+			name:           "parseLocation() should return nil if the input Latitude is out of range",
+			input:          []string{"1234", "91", "-0.172361", "2011-03-22 07:48:01"},
+			expectedOutput: nil,
+			expectedError:  true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -1,56 +1,222 @@
 package store
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
 const stationsFilename = "tube-stations"
 
-// Station defines the name and location of a London tube station
+// Networks a station can belong to
+const (
+	NetworkUnderground = "Underground"
+	NetworkDLR         = "DLR"
+	NetworkOverground  = "Overground"
+)
+
+// Station defines the name and location of a London tube station, and what is known of its service
 type Station struct {
 	Name      string
 	Latitude  float64
 	Longitude float64
+	// Lines are the lines serving the station, if known
+	Lines []string
+	// Zone is the fare zone of the station, e.g. "1" or "2/3" for a station on a boundary, if known
+	Zone string
+	// Network is the network the station belongs to, e.g. NetworkUnderground, if known
+	Network string
+	// StepFree reports whether the station has step-free access
+	StepFree bool
 }
 
-// Stations returns a slice of all tube stations
+// Stations returns a slice of all tube stations, skipping the lines that cannot be parsed
 func Stations() ([]Station, error) {
-	lines, err := read(stationsFilename)
+	stations, err := StationsWithMode(Lenient)
+
+	var diagnostics ParseErrors
+	if errors.As(err, &diagnostics) {
+		for _, diagnostic := range diagnostics {
+			logrus.Debug(fmt.Sprintf("Could not parse station: %s", diagnostic))
+		}
+		return stations, nil
+	}
 	if err != nil {
 		return []Station{}, err
 	}
 
-	var stations []Station
-	for _, line := range lines {
-		station, err := parseStation(line)
-		if err != nil {
-			logrus.Debug(fmt.Sprintf("Could not parse station %s: %s\n", line, err))
-			continue
+	return stations, nil
+}
+
+// StationsWithMode returns a slice of all tube stations, handling the lines that cannot be parsed according to mode
+func StationsWithMode(mode ParseMode) ([]Station, error) {
+	return collect(streamStations(nil), mode)
+}
+
+// stationFields are the fields of a station in the order of a headerless stations file. The first three are required.
+var stationFields = []string{"station", "lat", "lon", "lines", "zone", "network", "step-free"}
+
+// requiredStationFields is the number of fields a station must have
+const requiredStationFields = 3
+
+// stationParser parses the rows of a stations file. If its first row is a header naming a lat column, the columns
+// are mapped to the fields of a station by name, in any order, and the columns it does not know are ignored.
+// Otherwise, the columns are the fields of a station in order.
+type stationParser struct {
+	started bool
+	// columns are the positions of the fields of a station in the file's rows, -1 for a field the file lacks
+	columns []int
+}
+
+// parse returns the station of a row, or nil for the header
+func (p *stationParser) parse(row []string) (*Station, error) {
+	if !p.started {
+		p.started = true
+		if columns, ok := stationColumns(row); ok {
+			p.columns = columns
+			return nil, nil
+		}
+	}
+	if p.columns == nil {
+		return parseStation(row)
+	}
+
+	fields := make([]string, len(stationFields))
+	for i, column := range p.columns {
+		if column >= 0 && column < len(row) {
+			fields[i] = row[column]
+		} else if i < requiredStationFields {
+			return nil, fieldError(column+1, stationFields[i], fmt.Sprintf("missing field, expected %d fields but got %d", column+1, len(row)))
 		}
+	}
 
-		stations = append(stations, *station)
+	station, err := parseStation(fields)
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) && parseErr.Column > 0 {
+		// locate the field in the file rather than in the station
+		parseErr.Column = p.columns[parseErr.Column-1] + 1
 	}
+	return station, err
+}
 
-	return stations, nil
+// stationColumns returns the positions of the fields of a station in a header row, or false if it is not a header
+func stationColumns(header []string) ([]int, bool) {
+	columns := make([]int, len(stationFields))
+	for i, field := range stationFields {
+		columns[i] = -1
+		for column, name := range header {
+			name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+			if slices.ContainsFunc(DefaultJSONFields[field], func(alias string) bool { return strings.ToLower(alias) == name }) {
+				columns[i] = column
+				break
+			}
+		}
+	}
+
+	for _, column := range columns[:requiredStationFields] {
+		if column < 0 {
+			return nil, false
+		}
+	}
+	return columns, true
 }
 
+// parseStation parses the fields of a station in order, the optional ones being empty or missing if unknown
 func parseStation(line []string) (*Station, error) {
+	if err := requireFields(line, stationFields[:requiredStationFields]...); err != nil {
+		return nil, err
+	}
+
 	name := line[0]
-	latitude, err := strconv.ParseFloat(line[1], 64)
+	if strings.TrimSpace(name) == "" {
+		return nil, fieldError(1, stationFields[0], "name must not be empty")
+	}
+
+	latitude, err := parseCoordinate(line, 2, stationFields[1], 90)
 	if err != nil {
 		return nil, err
 	}
-	longitude, err := strconv.ParseFloat(line[2], 64)
+
+	longitude, err := parseCoordinate(line, 3, stationFields[2], 180)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Station{
+	station := &Station{
 		Name:      name,
 		Latitude:  latitude,
 		Longitude: longitude,
-	}, nil
+	}
+	optional := func(column int) string {
+		if column > len(line) {
+			return ""
+		}
+		return strings.TrimSpace(line[column-1])
+	}
+
+	lines, err := parseLines(optional(4))
+	if err != nil {
+		return nil, fieldError(4, stationFields[3], err.Error())
+	}
+	station.Lines = lines
+	station.Zone = optional(5)
+	station.Network = parseNetwork(optional(6))
+
+	if value := optional(7); value != "" {
+		stepFree, ok := parseYesNo(value)
+		if !ok {
+			return nil, fieldError(7, stationFields[6], fmt.Sprintf("%q is neither yes nor no", value))
+		}
+		station.StepFree = stepFree
+	}
+	return station, nil
+}
+
+// parseLines parses lines separated by | or ;, or given as a JSON array of strings
+func parseLines(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(value, "[") {
+		var lines []string
+		if err := json.Unmarshal([]byte(value), &lines); err != nil {
+			return nil, fmt.Errorf("%q is not a list of lines", value)
+		}
+		return lines, nil
+	}
+
+	var lines []string
+	for _, line := range strings.FieldsFunc(value, func(r rune) bool { return r == '|' || r == ';' }) {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// parseNetwork returns the known networks with their usual case, and any other network as it is
+func parseNetwork(value string) string {
+	for _, network := range []string{NetworkUnderground, NetworkDLR, NetworkOverground} {
+		if strings.EqualFold(value, network) {
+			return network
+		}
+	}
+	return value
+}
+
+// parseYesNo parses a flag written as yes/no, y/n or any boolean strconv.ParseBool accepts
+func parseYesNo(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "yes", "y":
+		return true, true
+	case "no", "n":
+		return false, true
+	}
+	flag, err := strconv.ParseBool(value)
+	return flag, err == nil
 }
@@ -2,6 +2,7 @@ package store
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -116,6 +117,49 @@ func TestParseStation(t *testing.T) {
 			expectedOutput: nil,
 			expectedError:  true,
 		},
+		{
+			name:           "parseStation() should return nil if the input is missing fields",
+			input:          []string{"Aldgate", "51.479015"},
+			expectedOutput: nil,
+			expectedError:  true,
+		},
+		{
+			name:           "parseStation() should return nil if the input Name is empty",
+			input:          []string{" ", "51.479015", "-0.172361"},
+			expectedOutput: nil,
+			expectedError:  true,
+		},
+		{
+			name:  "parseStation() should return a Station with its optional fields",
+			input: []string{"Bank", "51.513347", "-0.089", "Central|Northern; Waterloo & City", "1", "dlr", "yes"},
+			expectedOutput: &Station{
+				Name:      "Bank",
+				Latitude:  51.513347,
+				Longitude: -0.089,
+				Lines:     []string{"Central", "Northern", "Waterloo & City"},
+				Zone:      "1",
+				Network:   NetworkDLR,
+				StepFree:  true,
+			},
+			expectedError: false,
+		},
+		{
+			name:  "parseStation() should read lines given as a JSON array",
+			input: []string{"Bank", "51.513347", "-0.089", `["Central","Northern"]`, "", "", ""},
+			expectedOutput: &Station{
+				Name:      "Bank",
+				Latitude:  51.513347,
+				Longitude: -0.089,
+				Lines:     []string{"Central", "Northern"},
+			},
+			expectedError: false,
+		},
+		{
+			name:           "parseStation() should return nil if the input step-free flag is invalid",
+			input:          []string{"Bank", "51.513347", "-0.089", "", "", "", "maybe"},
+			expectedOutput: nil,
+			expectedError:  true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -131,3 +175,53 @@ func TestParseStation(t *testing.T) {
 		})
 	}
 }
+
+func TestStationParser(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          string
+		expectedOutput []Station
+		expectedErrors []string
+	}{
+		{
+			name:  "stationParser should read a headerless file in order",
+			input: "\"Acton Town\",51.503071,-0.280303\n\"Aldgate\",51.514342,-0.075627\n",
+			expectedOutput: []Station{
+				{Name: "Acton Town", Latitude: 51.503071, Longitude: -0.280303},
+				{Name: "Aldgate", Latitude: 51.514342, Longitude: -0.075627},
+			},
+		},
+		{
+			name: "stationParser should map the columns of a header by name and ignore unknown ones",
+			input: `Latitude,Longitude,Name,Operator,Fare Zone,Step-Free,Lines,Network
+51.503071,-0.280303,Acton Town,TfL,3,no,District|Piccadilly,Underground
+51.514342,-0.075627,Aldgate,TfL,1,yes,Circle|Metropolitan,underground
+51.51,hello,Bank,TfL,1,yes,,DLR
+`,
+			expectedOutput: []Station{
+				{Name: "Acton Town", Latitude: 51.503071, Longitude: -0.280303, Lines: []string{"District", "Piccadilly"}, Zone: "3", Network: NetworkUnderground},
+				{Name: "Aldgate", Latitude: 51.514342, Longitude: -0.075627, Lines: []string{"Circle", "Metropolitan"}, Zone: "1", Network: NetworkUnderground, StepFree: true},
+			},
+			expectedErrors: []string{`test.csv:4: column 2 (lon): "hello" is not a number`},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When
+			var output []Station
+			var errors []string
+			for station, err := range scanCSV(strings.NewReader(testCase.input), "test.csv", (&stationParser{}).parse) {
+				if err != nil {
+					errors = append(errors, err.Error())
+					continue
+				}
+				output = append(output, station)
+			}
+
+			// Then
+			assert.Equal(t, testCase.expectedOutput, output)
+			assert.Equal(t, testCase.expectedErrors, errors)
+		})
+	}
+}
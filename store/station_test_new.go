@@ -19,14 +19,14 @@ func TestStations_WithoutMonkey(t *testing.T) {
 			mockRepo: &MockStationRepository{
 				GetStationsFunc: func() ([]Station, error) {
 					return []Station{
-						{"Aldgate", 51.474579, -0.171834},
-						{"Camden Town", 51.479015, -0.172361},
+						{Name: "Aldgate", Latitude: 51.474579, Longitude: -0.171834},
+						{Name: "Camden Town", Latitude: 51.479015, Longitude: -0.172361},
 					}, nil
 				},
 			},
 			expectedOutput: []Station{
-				{"Aldgate", 51.474579, -0.171834},
-				{"Camden Town", 51.479015, -0.172361},
+				{Name: "Aldgate", Latitude: 51.474579, Longitude: -0.171834},
+				{Name: "Camden Town", Latitude: 51.479015, Longitude: -0.172361},
 			},
 		},
 		{
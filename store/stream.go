@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RouteStreamer defines methods for streaming route data without loading a whole route in memory
+type RouteStreamer interface {
+	StreamRoute(id int) iter.Seq2[Location, error]
+}
+
+// RouteIterator returns the locations of the route of a drone with given ID as they are parsed. A line that
+// cannot be parsed yields a *ParseError and iteration goes on, any other error ends the iteration.
+func RouteIterator(id int) iter.Seq2[Location, error] {
+	return TimeFormat{}.streamRoute(id, nil)
+}
+
+// StreamRoute returns the locations of a route from a repository, streamed if the repository supports it.
+// The sequence ends after the first error.
+func StreamRoute(repo RouteRepository, id int) iter.Seq2[Location, error] {
+	if streamer, ok := repo.(RouteStreamer); ok {
+		return streamer.StreamRoute(id)
+	}
+	return streamLoaded(repo, id)
+}
+
+// streamLoaded loads a whole route from a repository and returns its locations one by one
+func streamLoaded(repo RouteRepository, id int) iter.Seq2[Location, error] {
+	return func(yield func(Location, error) bool) {
+		route, err := repo.GetRoute(id)
+		if err != nil {
+			yield(Location{}, err)
+			return
+		}
+
+		for _, location := range route {
+			if !yield(location, nil) {
+				return
+			}
+		}
+	}
+}
+
+// StreamRoute streams the locations of a route file as they are parsed, and then as they are appended if the
+// route is followed. Cleaning needs the whole route, so a route is only streamed with bounded memory if the
+// repository has no Cleaner.
+func (r DefaultRouteRepository) StreamRoute(id int) iter.Seq2[Location, error] {
+	if r.Follow {
+		return skipUnparsed(r.followRoute(id), r.Mode)
+	}
+	if r.Cleaner != nil {
+		return streamLoaded(r, id)
+	}
+
+	return skipUnparsed(r.TimeFormat.streamRoute(id, r.JSONFields), r.Mode)
+}
+
+// skipUnparsed ends a sequence after its first error in Strict mode. In Lenient mode, it skips the items that
+// cannot be parsed with a warning.
+func skipUnparsed[T any](seq iter.Seq2[T, error], mode ParseMode) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for item, err := range seq {
+			var parseErr *ParseError
+			if err != nil && mode == Lenient && errors.As(err, &parseErr) {
+				logrus.Warn(fmt.Sprintf("Skipped line: %s", parseErr))
+				continue
+			}
+
+			if !yield(item, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// followRoute follows the CSV route file of a drone, failing if its route file is in another format
+func (r DefaultRouteRepository) followRoute(id int) iter.Seq2[Location, error] {
+	return func(yield func(Location, error) bool) {
+		if err := CheckFollowable(id); err != nil {
+			yield(Location{}, err)
+			return
+		}
+		for location, err := range followCSV(strconv.Itoa(id), r.IdleTimeout, r.TimeFormat.parseLocation) {
+			if !yield(location, err) {
+				return
+			}
+		}
+	}
+}
+
+// Live reports that the locations of followed routes are flown as they are written
+func (r DefaultRouteRepository) Live() bool {
+	return r.Follow
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestData writes a data file in a temporary data directory, and makes it the working directory of the test
+func writeTestData(t *testing.T, filename, content string, compressed bool) {
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "data"), 0o755))
+	t.Chdir(dir)
+
+	addTestData(t, filename, content, compressed)
+}
+
+// addTestData writes another data file in the data directory of the working directory
+func addTestData(t *testing.T, filename, content string, compressed bool) {
+	file, err := os.Create(filepath.Join("data", filename))
+	assert.NoError(t, err)
+	defer file.Close()
+
+	if compressed {
+		writer := gzip.NewWriter(file)
+		_, err = writer.Write([]byte(content))
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Close())
+	} else {
+		_, err = file.WriteString(content)
+		assert.NoError(t, err)
+	}
+}
+
+func TestRouteIterator(t *testing.T) {
+	testCases := []struct {
+		name       string
+		filename   string
+		compressed bool
+	}{
+		{name: "RouteIterator() should stream a CSV file", filename: "1234.csv"},
+		{name: "RouteIterator() should stream a gzip-compressed CSV file", filename: "1234.csv.gz", compressed: true},
+		{name: "RouteIterator() should detect gzip-compressed content whatever its name", filename: "1234.csv", compressed: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			writeTestData(t, testCase.filename, testRouteCSV, testCase.compressed)
+
+			var locations []Location
+			var diagnostics []*ParseError
+			for location, err := range RouteIterator(1234) {
+				if err != nil {
+					var diagnostic *ParseError
+					assert.ErrorAs(t, err, &diagnostic)
+					diagnostics = append(diagnostics, diagnostic)
+					continue
+				}
+				locations = append(locations, location)
+			}
+
+			assert.Len(t, locations, 2)
+			assert.Len(t, diagnostics, 2)
+			assert.Equal(t, 2, diagnostics[0].Line)
+		})
+	}
+}
+
+func TestRouteIterator_MissingFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// Given no route file
+	// Then iterating should yield a single error
+	var errs []error
+	for _, err := range RouteIterator(1234) {
+		errs = append(errs, err)
+	}
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], os.ErrNotExist)
+}
+
+func TestDefaultRouteRepository_StreamRoute(t *testing.T) {
+	testCases := []struct {
+		name              string
+		repo              DefaultRouteRepository
+		expectedLocations int
+		expectedError     bool
+	}{
+		{
+			name:              "StreamRoute() should skip the lines that cannot be parsed in Lenient mode",
+			repo:              DefaultRouteRepository{},
+			expectedLocations: 2,
+		},
+		{
+			name:              "StreamRoute() should end on the first line that cannot be parsed in Strict mode",
+			repo:              DefaultRouteRepository{Mode: Strict},
+			expectedLocations: 1,
+			expectedError:     true,
+		},
+		{
+			name:              "StreamRoute() should stream a cleaned route",
+			repo:              DefaultRouteRepository{Cleaner: DefaultRouteCleaner()},
+			expectedLocations: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			writeTestData(t, "1234.csv", testRouteCSV, false)
+
+			var locations []Location
+			var errs []error
+			for location, err := range StreamRoute(testCase.repo, 1234) {
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				locations = append(locations, location)
+			}
+
+			assert.Len(t, locations, testCase.expectedLocations)
+			if testCase.expectedError {
+				assert.Len(t, errs, 1)
+			} else {
+				assert.Empty(t, errs)
+			}
+		})
+	}
+}
+
+func TestStreamRoute_Repository(t *testing.T) {
+	// Given a repository that cannot stream
+	repo := &MockRouteRepository{
+		GetRouteFunc: func(id int) ([]Location, error) {
+			return []Location{{DroneID: id}, {DroneID: id}}, nil
+		},
+	}
+
+	// Then its route should be streamed once loaded, and iteration should stop when asked to
+	count := 0
+	for location, err := range StreamRoute(repo, 1234) {
+		assert.NoError(t, err)
+		assert.Equal(t, 1234, location.DroneID)
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+}
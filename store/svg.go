@@ -0,0 +1,180 @@
+package store
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	svgMarginPx         = 40
+	svgStationRadiusPx  = 3
+	svgReportRadiusPx   = 5
+	svgDefaultWidthPx   = 1000
+	svgDefaultHeightPx  = 1000
+	svgLabelFontSizePx  = 10
+	kmPerDegreeLatitude = math.Pi * earthRadiusKm / 180
+)
+
+// svgRouteColors are the colors of the routes, in the order of the routes
+var svgRouteColors = []string{"#1f77b4", "#9467bd", "#17becf", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#2ca02c"}
+
+// svgConditionColors are the colors of the reports by traffic condition, as in KML
+var svgConditionColors = map[string]string{
+	TrafficHeavy:    "#ff0000",
+	TrafficModerate: "#ffa500",
+	TrafficLight:    "#00c000",
+}
+
+// SVGOptions defines how an SVG map is drawn
+type SVGOptions struct {
+	// WidthPx is the width of the map in pixels, 1000 if not set
+	WidthPx int
+	// MaxHeightPx is the height in pixels the map is fitted within, 1000 if not set. The map is as high as the data
+	// it shows, and narrower data is centered across the width.
+	MaxHeightPx int
+	// VisibilityRadiusInKm shades a circle of this radius around every station drawn if it is positive
+	VisibilityRadiusInKm float64
+}
+
+// svgProjection projects locations on an SVG map with an equirectangular projection fitted to bounds
+type svgProjection struct {
+	north, west float64
+	// cosLatitude shrinks longitudes so that distances are the same in every direction at the middle latitude
+	cosLatitude float64
+	pxPerDegree float64
+}
+
+// WriteSVG writes a map of routes as colored polylines, traffic reports as markers colored by condition, and every
+// station as a named dot, over the visibility circles of the stations. The map is fitted to all of them.
+func WriteSVG(w io.Writer, routes [][]Location, stations []Station, reports []TrafficReport, options SVGOptions) error {
+	var latitudes, longitudes []float64
+	for _, route := range routes {
+		for _, location := range route {
+			latitudes, longitudes = append(latitudes, location.Latitude), append(longitudes, location.Longitude)
+		}
+	}
+	for _, report := range reports {
+		latitudes, longitudes = append(latitudes, report.Latitude), append(longitudes, report.Longitude)
+	}
+	for _, station := range stations {
+		latitudes, longitudes = append(latitudes, station.Latitude), append(longitudes, station.Longitude)
+	}
+
+	width := options.WidthPx
+	if width <= 0 {
+		width = svgDefaultWidthPx
+	}
+	maxHeight := options.MaxHeightPx
+	if maxHeight <= 0 {
+		maxHeight = svgDefaultHeightPx
+	}
+	projection, height := fitSVGProjection(latitudes, longitudes, width, maxHeight, options.VisibilityRadiusInKm)
+
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintln(writer, `<title>Drone simulation</title>`)
+	fmt.Fprintf(writer, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", width, height)
+
+	fmt.Fprintln(writer, `<g id="visibility" fill="#888888" fill-opacity="0.2" stroke="#888888" stroke-width="1">`)
+	if options.VisibilityRadiusInKm > 0 {
+		radius := options.VisibilityRadiusInKm / kmPerDegreeLatitude * projection.pxPerDegree
+		for _, station := range stations {
+			x, y := projection.project(station.Latitude, station.Longitude)
+			fmt.Fprintf(writer, `<circle cx="%s" cy="%s" r="%s"/>`+"\n", svgNumber(x), svgNumber(y), svgNumber(radius))
+		}
+	}
+	fmt.Fprintln(writer, `</g>`)
+
+	fmt.Fprintln(writer, `<g id="routes" fill="none" stroke-width="2">`)
+	for i, route := range routes {
+		points := make([]string, len(route))
+		for j, location := range route {
+			x, y := projection.project(location.Latitude, location.Longitude)
+			points[j] = svgNumber(x) + "," + svgNumber(y)
+		}
+		title := ""
+		if len(route) > 0 {
+			title = fmt.Sprintf("<title>Drone %d</title>", route[0].DroneID)
+		}
+		fmt.Fprintf(writer, `<polyline stroke="%s" points="%s">%s</polyline>`+"\n", svgRouteColors[i%len(svgRouteColors)], strings.Join(points, " "), title)
+	}
+	fmt.Fprintln(writer, `</g>`)
+
+	fmt.Fprintf(writer, `<g id="stations" fill="#333333" font-family="sans-serif" font-size="%d">`+"\n", svgLabelFontSizePx)
+	for _, station := range stations {
+		x, y := projection.project(station.Latitude, station.Longitude)
+		fmt.Fprintf(writer, `<circle cx="%s" cy="%s" r="%d"/>`+"\n", svgNumber(x), svgNumber(y), svgStationRadiusPx)
+		fmt.Fprintf(writer, `<text x="%s" y="%s">%s</text>`+"\n", svgNumber(x+svgStationRadiusPx+2), svgNumber(y-svgStationRadiusPx), svgText(station.Name))
+	}
+	fmt.Fprintln(writer, `</g>`)
+
+	fmt.Fprintln(writer, `<g id="reports" stroke="#000000" stroke-width="0.5">`)
+	for _, report := range reports {
+		x, y := projection.project(report.Latitude, report.Longitude)
+		color, ok := svgConditionColors[report.Condition]
+		if !ok {
+			color = "#888888"
+		}
+		fmt.Fprintf(writer, `<circle cx="%s" cy="%s" r="%d" fill="%s"><title>%s</title></circle>`+"\n", svgNumber(x), svgNumber(y), svgReportRadiusPx, color,
+			svgText(fmt.Sprintf("%s: %s reported by drone %d at %s", report.Station, report.Condition, report.DroneID, report.Time.Format("15:04:05"))))
+	}
+	fmt.Fprintln(writer, `</g>`)
+
+	fmt.Fprintln(writer, `</svg>`)
+	return writer.Flush()
+}
+
+// fitSVGProjection returns the projection fitting the given coordinates, padded by a margin in km, into a map of
+// given width and at most the given height, centered across the width, and the height of the map
+func fitSVGProjection(latitudes, longitudes []float64, width, maxHeight int, marginInKm float64) (svgProjection, int) {
+	north, south, west, east := 0.0, 0.0, 0.0, 0.0
+	for i := range latitudes {
+		if i == 0 {
+			north, south, west, east = latitudes[i], latitudes[i], longitudes[i], longitudes[i]
+		}
+		north, south = math.Max(north, latitudes[i]), math.Min(south, latitudes[i])
+		west, east = math.Min(west, longitudes[i]), math.Max(east, longitudes[i])
+	}
+
+	cosLatitude := math.Cos((north + south) / 2 * math.Pi / 180)
+	margin := marginInKm / kmPerDegreeLatitude
+	north, south = north+margin, south-margin
+	west, east = west-margin/cosLatitude, east+margin/cosLatitude
+
+	spanX, spanY := (east-west)*cosLatitude, north-south
+	innerWidth, innerHeight := float64(width-2*svgMarginPx), float64(maxHeight-2*svgMarginPx)
+	// a single location is shown a kilometre across
+	pxPerDegree := innerWidth / math.Max(spanX, 1/kmPerDegreeLatitude)
+	if spanY > 0 {
+		pxPerDegree = math.Min(pxPerDegree, innerHeight/spanY)
+	}
+	offsetX := svgMarginPx + (innerWidth-spanX*pxPerDegree)/2
+	projection := svgProjection{
+		north:       north + svgMarginPx/pxPerDegree,
+		west:        west - offsetX/pxPerDegree/cosLatitude,
+		cosLatitude: cosLatitude,
+		pxPerDegree: pxPerDegree,
+	}
+	return projection, min(int(math.Ceil(spanY*pxPerDegree))+2*svgMarginPx, maxHeight)
+}
+
+// project returns the position of a location on the map
+func (p svgProjection) project(latitude, longitude float64) (float64, float64) {
+	return (longitude - p.west) * p.cosLatitude * p.pxPerDegree, (p.north - latitude) * p.pxPerDegree
+}
+
+// svgNumber formats a coordinate of the map to a tenth of a pixel
+func svgNumber(value float64) string {
+	return fmt.Sprintf("%.1f", value)
+}
+
+// svgText escapes text for an SVG document
+func svgText(text string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// svgDocument reads the elements of an SVG map checked by the tests
+type svgDocument struct {
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
+	Groups []struct {
+		ID      string `xml:"id,attr"`
+		Circles []struct {
+			Fill string `xml:"fill,attr"`
+		} `xml:"circle"`
+		Polylines []struct {
+			Stroke string `xml:"stroke,attr"`
+			Title  string `xml:"title"`
+		} `xml:"polyline"`
+		Texts []string `xml:"text"`
+	} `xml:"g"`
+}
+
+func TestWriteSVG(t *testing.T) {
+	start := time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	route := []Location{
+		{DroneID: 5937, Latitude: 51.4965, Longitude: -0.1449, Time: start},
+		{DroneID: 5937, Latitude: 51.5014, Longitude: -0.1247, Time: start.Add(time.Minute)},
+	}
+	stations := []Station{
+		{Name: "Victoria", Latitude: 51.4965, Longitude: -0.1447},
+		{Name: "Westminster & Co", Latitude: 51.5010, Longitude: -0.1254},
+		{Name: "Epping", Latitude: 51.6937, Longitude: 0.1139},
+	}
+	reports := []TrafficReport{
+		{DroneID: 5937, Station: "Victoria", Latitude: 51.4965, Longitude: -0.1447, Time: start, Condition: TrafficHeavy},
+		{DroneID: 5937, Station: "Westminster & Co", Latitude: 51.5010, Longitude: -0.1254, Time: start.Add(time.Minute), Condition: TrafficLight},
+	}
+
+	testCases := []struct {
+		name               string
+		routes             [][]Location
+		reports            []TrafficReport
+		options            SVGOptions
+		expectedStations   []string
+		expectedVisibility int
+	}{
+		{
+			name:             "WriteSVG() should fit the map to the routes, reports and every station",
+			routes:           [][]Location{route},
+			reports:          reports,
+			expectedStations: []string{"Victoria", "Westminster & Co", "Epping"},
+		},
+		{
+			name:               "WriteSVG() should shade the visibility circles of the stations",
+			routes:             [][]Location{route},
+			reports:            reports,
+			options:            SVGOptions{VisibilityRadiusInKm: 0.35},
+			expectedStations:   []string{"Victoria", "Westminster & Co", "Epping"},
+			expectedVisibility: 3,
+		},
+		{
+			name:             "WriteSVG() should fit the map to the stations without routes nor reports",
+			expectedStations: []string{"Victoria", "Westminster & Co", "Epping"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// When
+			var buffer bytes.Buffer
+			assert.NoError(WriteSVG(&buffer, testCase.routes, stations, testCase.reports, testCase.options))
+
+			// Then
+			var document svgDocument
+			assert.NoError(xml.Unmarshal(buffer.Bytes(), &document))
+			assert.Equal(svgDefaultWidthPx, document.Width)
+			assert.Greater(document.Height, 2*svgMarginPx)
+			assert.LessOrEqual(document.Height, svgDefaultHeightPx)
+
+			groups := map[string]int{}
+			for i, group := range document.Groups {
+				groups[group.ID] = i
+			}
+			assert.Len(document.Groups[groups["visibility"]].Circles, testCase.expectedVisibility)
+			assert.Equal(testCase.expectedStations, document.Groups[groups["stations"]].Texts)
+			assert.Len(document.Groups[groups["routes"]].Polylines, len(testCase.routes))
+			if len(testCase.routes) > 0 {
+				assert.Equal("Drone 5937", document.Groups[groups["routes"]].Polylines[0].Title)
+			}
+
+			var fills []string
+			for _, circle := range document.Groups[groups["reports"]].Circles {
+				fills = append(fills, circle.Fill)
+			}
+			if len(testCase.reports) > 0 {
+				assert.Equal([]string{"#ff0000", "#00c000"}, fills)
+			}
+		})
+	}
+}
+
+func TestSVGProjection(t *testing.T) {
+	// Given a map fitted to two locations a kilometre apart west to east
+	latitudes, longitudes := []float64{51.5, 51.5}, []float64{-0.1, -0.1 + 1/(kmPerDegreeLatitude*0.6225)}
+	projection, height := fitSVGProjection(latitudes, longitudes, 1000, 1000, 0)
+
+	// When
+	westX, westY := projection.project(latitudes[0], longitudes[0])
+	eastX, _ := projection.project(latitudes[1], longitudes[1])
+
+	// Then they should be drawn from margin to margin
+	assert.InDelta(t, svgMarginPx, westX, 1)
+	assert.InDelta(t, 1000-svgMarginPx, eastX, 1)
+	assert.InDelta(t, svgMarginPx, westY, 1)
+	assert.Equal(t, 2*svgMarginPx, height)
+}
+
+func TestSVGProjection_Tall(t *testing.T) {
+	// Given a map fitted to two locations ten kilometres apart south to north
+	latitudes, longitudes := []float64{51.5, 51.5 + 10/kmPerDegreeLatitude}, []float64{-0.1, -0.1}
+	projection, height := fitSVGProjection(latitudes, longitudes, 1000, 600, 0)
+
+	// When
+	southX, southY := projection.project(latitudes[0], longitudes[0])
+	_, northY := projection.project(latitudes[1], longitudes[1])
+
+	// Then they should be drawn from margin to margin of the highest map, centered across its width
+	assert.Equal(t, 600, height)
+	assert.InDelta(t, svgMarginPx, northY, 1)
+	assert.InDelta(t, 600-svgMarginPx, southY, 1)
+	assert.InDelta(t, 500, southX, 1)
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EpochUnit defines the unit of timestamps given as a number of units since the Unix epoch
+type EpochUnit int
+
+const (
+	// NoEpoch parses timestamps as text
+	NoEpoch EpochUnit = iota
+	// EpochSeconds parses timestamps as seconds since the Unix epoch
+	EpochSeconds
+	// EpochMillis parses timestamps as milliseconds since the Unix epoch
+	EpochMillis
+)
+
+// DefaultTimeLayouts are the layouts tried for timestamps when a TimeFormat has none
+var DefaultTimeLayouts = []string{
+	time.DateTime,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z0700",
+}
+
+// TimeFormat defines how the timestamps of data files are parsed
+type TimeFormat struct {
+	// Layouts are tried in order for textual timestamps, DefaultTimeLayouts if empty
+	Layouts []string
+	// Location is the time zone of timestamps without an offset, UTC if nil
+	Location *time.Location
+	// Epoch parses timestamps as numbers since the Unix epoch instead of text
+	Epoch EpochUnit
+}
+
+// NewTimeFormat returns a time format from its textual options: comma-separated layouts, an IANA time zone name,
+// and an epoch unit ("s" or "ms"), any of which may be empty for the default
+func NewTimeFormat(layouts, zone, epoch string) (TimeFormat, error) {
+	var format TimeFormat
+	if layouts != "" {
+		format.Layouts = strings.Split(layouts, ",")
+	}
+
+	if zone != "" {
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return format, err
+		}
+		format.Location = location
+	}
+
+	switch epoch {
+	case "":
+	case "s":
+		format.Epoch = EpochSeconds
+	case "ms":
+		format.Epoch = EpochMillis
+	default:
+		return format, fmt.Errorf("unknown epoch unit %q, expected s or ms", epoch)
+	}
+	return format, nil
+}
+
+// Parse returns the time of a timestamp. Timestamps without an offset are in the format's time zone, including
+// its daylight saving time, e.g. BST for Europe/London.
+func (f TimeFormat) Parse(value string) (time.Time, error) {
+	location := f.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	if f.Epoch != NoEpoch {
+		units, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a Unix timestamp", value)
+		}
+		if f.Epoch == EpochMillis {
+			return time.UnixMilli(units).In(location), nil
+		}
+		return time.Unix(units, 0).In(location), nil
+	}
+
+	layouts := f.Layouts
+	if len(layouts) == 0 {
+		layouts = DefaultTimeLayouts
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time", value)
+}
+
+// Format returns the timestamp of a time as the format parses it: a number of epoch units, or the time in the
+// format's time zone written with its first layout, or RFC 3339 if it has none
+func (f TimeFormat) Format(t time.Time) string {
+	switch f.Epoch {
+	case EpochSeconds:
+		return strconv.FormatInt(t.Unix(), 10)
+	case EpochMillis:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+
+	location := f.Location
+	if location == nil {
+		location = time.UTC
+	}
+	if len(f.Layouts) == 0 {
+		return t.In(location).Format(time.RFC3339Nano)
+	}
+	return t.In(location).Format(f.Layouts[0])
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeFormat_Parse(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		format         TimeFormat
+		input          string
+		expectedOutput time.Time
+		expectedError  string
+	}{
+		{
+			name:           "Parse() should read the default layout in UTC",
+			input:          "2011-03-22 07:55:26",
+			expectedOutput: time.Date(2011, 3, 22, 7, 55, 26, 0, time.UTC),
+		},
+		{
+			name:           "Parse() should read RFC3339 with its offset",
+			input:          "2011-03-22T07:55:26+01:00",
+			expectedOutput: time.Date(2011, 3, 22, 6, 55, 26, 0, time.UTC),
+		},
+		{
+			name:           "Parse() should read ISO 8601 with fractional seconds and no offset",
+			input:          "2011-03-22T07:55:26.5",
+			expectedOutput: time.Date(2011, 3, 22, 7, 55, 26, 500000000, time.UTC),
+		},
+		{
+			name:           "Parse() should read times in GMT for Europe/London in winter",
+			format:         TimeFormat{Location: london},
+			input:          "2011-03-22 07:55:26",
+			expectedOutput: time.Date(2011, 3, 22, 7, 55, 26, 0, time.UTC),
+		},
+		{
+			name:           "Parse() should read times in BST for Europe/London in summer",
+			format:         TimeFormat{Location: london},
+			input:          "2011-07-22 07:55:26",
+			expectedOutput: time.Date(2011, 7, 22, 6, 55, 26, 0, time.UTC),
+		},
+		{
+			name:           "Parse() should try custom layouts in order",
+			format:         TimeFormat{Layouts: []string{"2006-01-02", "02/01/2006 15:04"}},
+			input:          "22/03/2011 07:55",
+			expectedOutput: time.Date(2011, 3, 22, 7, 55, 0, 0, time.UTC),
+		},
+		{
+			name:           "Parse() should read epoch seconds",
+			format:         TimeFormat{Epoch: EpochSeconds},
+			input:          "1300780526",
+			expectedOutput: time.Date(2011, 3, 22, 7, 55, 26, 0, time.UTC),
+		},
+		{
+			name:           "Parse() should read epoch milliseconds",
+			format:         TimeFormat{Epoch: EpochMillis},
+			input:          "1300780526250",
+			expectedOutput: time.Date(2011, 3, 22, 7, 55, 26, 250000000, time.UTC),
+		},
+		{
+			name:          "Parse() should fail on a time in none of the layouts",
+			input:         "22/03/2011 07:55",
+			expectedError: `"22/03/2011 07:55" is not a time`,
+		},
+		{
+			name:          "Parse() should fail on a text in epoch mode",
+			format:        TimeFormat{Epoch: EpochSeconds},
+			input:         "2011-03-22 07:55:26",
+			expectedError: `"2011-03-22 07:55:26" is not a Unix timestamp`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// When
+			output, err := tc.format.Parse(tc.input)
+
+			// Then
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tc.expectedOutput.Equal(output), "expected %s, got %s", tc.expectedOutput, output)
+		})
+	}
+}
+
+func TestNewTimeFormat(t *testing.T) {
+	testCases := []struct {
+		name           string
+		layouts        string
+		zone           string
+		epoch          string
+		expectedOutput TimeFormat
+		expectedError  bool
+	}{
+		{
+			name:           "NewTimeFormat() should return the default format for empty options",
+			expectedOutput: TimeFormat{},
+		},
+		{
+			name:           "NewTimeFormat() should split comma-separated layouts",
+			layouts:        "2006-01-02,15:04",
+			expectedOutput: TimeFormat{Layouts: []string{"2006-01-02", "15:04"}},
+		},
+		{
+			name:           "NewTimeFormat() should read the epoch unit",
+			epoch:          "ms",
+			expectedOutput: TimeFormat{Epoch: EpochMillis},
+		},
+		{
+			name:          "NewTimeFormat() should fail on an unknown time zone",
+			zone:          "Europe/Atlantis",
+			expectedError: true,
+		},
+		{
+			name:          "NewTimeFormat() should fail on an unknown epoch unit",
+			epoch:         "ns",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// When
+			output, err := NewTimeFormat(tc.layouts, tc.zone, tc.epoch)
+
+			// Then
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedOutput, output)
+		})
+	}
+}
@@ -8,7 +8,7 @@ import (
 var errTest = errors.New("could not open file")
 
 func convertToTimeForTests(timeString string) time.Time {
-	timeTime, err := time.Parse(timeLayout, timeString)
+	timeTime, err := time.Parse(time.RFC3339, timeString)
 	if err != nil {
 		panic(err)
 	}
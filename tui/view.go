@@ -0,0 +1,354 @@
+// Package tui shows a live view of a simulation in a terminal, fed by the events of the dispatcher
+package tui
+
+import (
+	"bytes"
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/umahmood/haversine"
+	"golang.org/x/term"
+)
+
+// ErrNotTerminal is returned when running the view on an input that is not a terminal
+var ErrNotTerminal = errors.New("not a terminal")
+
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	bold        = "\x1b[1m"
+	reset       = "\x1b[0m"
+	ctrlC       = 3
+	rowFormat   = "%-6v %-20s %-19s %10s %7s %6s  %s"
+	feedFormat  = "%-8s  %-6v %-24s %s"
+	help        = "p pause/resume  s step  +/- speed  q quit"
+)
+
+var conditionColors = map[string]string{
+	store.TrafficHeavy:    "\x1b[1;31m",
+	store.TrafficModerate: "\x1b[1;33m",
+	store.TrafficLight:    "\x1b[1;32m",
+}
+
+// ViewConfig holds configuration for creating a terminal view
+type ViewConfig struct {
+	// Input is the terminal the keys are read from, os.Stdin if not set
+	Input *os.File
+	// Output is the terminal the view is drawn on, os.Stdout if not set
+	Output *os.File
+	// FeedSize is the number of reports kept in the feed, 100 if not set
+	FeedSize int
+	// Refresh is the real time between two redraws, 200ms if not set
+	Refresh time.Duration
+}
+
+// DroneRow describes the state of a drone as last seen in the events
+type DroneRow struct {
+	ID     int
+	Status string
+	// Reason is why the drone shut down, if it did
+	Reason   string
+	Location *store.Location
+	// SpeedKph is the speed of the drone between its last two waypoints
+	SpeedKph float64
+	// Battery is the charge left after the last move, from 0 when flat to 1 when full
+	Battery float64
+	// Memory is the number of reports the drone holds, out of agents.MaxMemory
+	Memory      int
+	LastStation string
+}
+
+// View is an EventSink keeping a row per drone and a feed of the latest traffic reports, drawn full-screen in a
+// terminal by Run
+type View struct {
+	mu       sync.Mutex
+	input    *os.File
+	output   *os.File
+	feedSize int
+	refresh  time.Duration
+	drones   map[int]*DroneRow
+	// time is the latest simulated time seen in the events, which an unsynchronized clock does not know
+	time time.Time
+	// feed holds the latest report and fusion events, newest first
+	feed []agents.Event
+}
+
+// clockStatus describes the dispatcher's clock in the header of the view
+type clockStatus struct {
+	Paused bool
+	Speed  float64
+}
+
+// NewView returns a new terminal view
+func NewView(config ViewConfig) *View {
+	v := &View{
+		input:    config.Input,
+		output:   config.Output,
+		feedSize: config.FeedSize,
+		refresh:  config.Refresh,
+		drones:   map[int]*DroneRow{},
+	}
+	if v.input == nil {
+		v.input = os.Stdin
+	}
+	if v.output == nil {
+		v.output = os.Stdout
+	}
+	if v.feedSize <= 0 {
+		v.feedSize = 100
+	}
+	if v.refresh <= 0 {
+		v.refresh = 200 * time.Millisecond
+	}
+	return v
+}
+
+// Handle updates the row of the drone of an event, and adds its reports to the feed
+func (v *View) Handle(event agents.Event) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if event.Time.After(v.time) {
+		v.time = event.Time
+	}
+	if event.Kind == agents.EventReport || event.Kind == agents.EventFusion {
+		v.feed = append([]agents.Event{event}, v.feed...)
+		if len(v.feed) > v.feedSize {
+			v.feed = v.feed[:v.feedSize]
+		}
+	}
+	if event.Kind == agents.EventFusion {
+		return
+	}
+
+	drone, ok := v.drones[event.DroneID]
+	if !ok {
+		drone = &DroneRow{ID: event.DroneID}
+		v.drones[event.DroneID] = drone
+	}
+	switch event.Kind {
+	case agents.EventLaunch:
+		*drone = DroneRow{ID: event.DroneID, Status: "on"}
+	case agents.EventRestart:
+		drone.Status = "on"
+		drone.Memory = 0
+	case agents.EventShutDown:
+		drone.Status = "off"
+		drone.Reason = event.Reason
+		drone.SpeedKph = 0
+	case agents.EventMove:
+		drone.SpeedKph = 0
+		if previous := drone.Location; previous != nil && event.Location.Time.After(previous.Time) {
+			_, km := haversine.Distance(
+				haversine.Coord{Lat: previous.Latitude, Lon: previous.Longitude},
+				haversine.Coord{Lat: event.Location.Latitude, Lon: event.Location.Longitude},
+			)
+			drone.SpeedKph = km / event.Location.Time.Sub(previous.Time).Hours()
+		}
+		drone.Location = event.Location
+		drone.Battery = event.Battery
+	case agents.EventReport:
+		drone.Memory++
+		drone.LastStation = event.Report.Station
+	}
+}
+
+// Drones returns the row of every drone seen in the events, ordered by ID
+func (v *View) Drones() []DroneRow {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	drones := make([]DroneRow, 0, len(v.drones))
+	for _, drone := range v.drones {
+		drones = append(drones, *drone)
+	}
+	sort.Slice(drones, func(i, j int) bool { return drones[i].ID < drones[j].ID })
+	return drones
+}
+
+// Run takes over the terminal until done is closed or q is pressed, redrawing the view and applying the keys
+// pressed to the dispatcher. The standard logger is silenced meanwhile, as it would garble the view.
+func (v *View) Run(dispatcher agents.Dispatcher, done <-chan struct{}) error {
+	fd := int(v.input.Fd())
+	if !term.IsTerminal(fd) {
+		return ErrNotTerminal
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	logger := logrus.StandardLogger()
+	out := logger.Out
+	logger.SetOutput(io.Discard)
+	defer logger.SetOutput(out)
+
+	fmt.Fprint(v.output, enterScreen)
+	defer fmt.Fprint(v.output, leaveScreen)
+
+	keys, stopped := make(chan byte), make(chan struct{})
+	defer close(stopped)
+	go readKeys(v.input, keys, stopped)
+	ticker := time.NewTicker(v.refresh)
+	defer ticker.Stop()
+
+	for {
+		v.draw(dispatcher)
+		select {
+		case <-done:
+			return nil
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+			} else if !press(key, dispatcher) {
+				return nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// readKeys sends every byte read from r to keys, until r is closed or, once its pending read returns, stopped is
+func readKeys(r io.Reader, keys chan<- byte, stopped <-chan struct{}) {
+	defer close(keys)
+
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		for _, key := range buf[:n] {
+			select {
+			case keys <- key:
+			case <-stopped:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// press applies a key to the dispatcher, returning false when it asks to quit
+func press(key byte, dispatcher agents.Dispatcher) bool {
+	switch key {
+	case 'p', ' ':
+		if dispatcher.Paused() {
+			dispatcher.Resume()
+		} else {
+			dispatcher.Pause()
+		}
+	case 's':
+		dispatcher.StepWaypoint()
+	case '+', '=':
+		dispatcher.SetSpeed(dispatcher.Speed() * 2)
+	case '-', '_':
+		dispatcher.SetSpeed(dispatcher.Speed() / 2)
+	case 'q', ctrlC:
+		// the simulation ends with the view, the drones land where they are
+		for _, drone := range dispatcher.ListDrones() {
+			dispatcher.RemoveDrone(drone.ID)
+		}
+		return false
+	}
+	return true
+}
+
+// draw redraws the whole view in place
+func (v *View) draw(dispatcher agents.Dispatcher) {
+	width, height, err := term.GetSize(int(v.output.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	status := clockStatus{Paused: dispatcher.Paused(), Speed: dispatcher.Speed()}
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H")
+	buf.WriteString(strings.Join(v.frame(status, width, height), "\x1b[K\r\n"))
+	buf.WriteString("\x1b[K\x1b[J")
+	v.output.Write(buf.Bytes())
+}
+
+// frame returns the lines of the view, fitted to a terminal of the given size
+func (v *View) frame(status clockStatus, width, height int) []string {
+	state := "running"
+	if status.Paused {
+		state = "paused"
+	}
+	v.mu.Lock()
+	now := v.time
+	v.mu.Unlock()
+	lines := []string{
+		fit(fmt.Sprintf("Drone simulation  %s  %s at %gx", now.Format(time.DateTime), state, status.Speed), width),
+		fit(help, width),
+		"",
+		bold + fit(fmt.Sprintf(rowFormat, "DRONE", "STATE", "POSITION", "SPEED", "BATTERY", "MEMORY", "LAST STATION"), width) + reset,
+	}
+	for _, drone := range v.Drones() {
+		lines = append(lines, fit(formatDrone(drone), width))
+	}
+	lines = append(lines, "", bold+fit("TRAFFIC REPORTS", width)+reset)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, event := range v.feed {
+		if len(lines) >= height {
+			break
+		}
+		lines = append(lines, colorCondition(fit(formatFeed(event), width), event.Report.Condition))
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+func formatDrone(drone DroneRow) string {
+	state := drone.Status
+	if drone.Reason != "" {
+		state = fmt.Sprintf("%s (%s)", state, drone.Reason)
+	}
+	var position, speed, battery string
+	if drone.Location != nil {
+		position = fmt.Sprintf("%.5f, %.5f", drone.Location.Latitude, drone.Location.Longitude)
+		speed = fmt.Sprintf("%.1f km/h", drone.SpeedKph)
+		battery = fmt.Sprintf("%.0f%%", drone.Battery*100)
+	}
+	memory := fmt.Sprintf("%d/%d", drone.Memory, agents.MaxMemory)
+	return fmt.Sprintf(rowFormat, drone.ID, state, position, speed, battery, memory, drone.LastStation)
+}
+
+func formatFeed(event agents.Event) string {
+	var drone any = event.Report.DroneID
+	if event.Kind == agents.EventFusion {
+		drone = "fused"
+	}
+	return fmt.Sprintf(feedFormat, event.Time.Format(time.TimeOnly), drone, event.Report.Station, event.Report.Condition)
+}
+
+// fit cuts a line to the width of the terminal
+func fit(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return line
+}
+
+// colorCondition colors the traffic condition ending a line, unless it was cut
+func colorCondition(line, condition string) string {
+	color, ok := conditionColors[condition]
+	if !ok || !strings.HasSuffix(line, condition) {
+		return line
+	}
+	return strings.TrimSuffix(line, condition) + color + condition + reset
+}
@@ -0,0 +1,237 @@
+package tui
+
+import (
+	"drone_simulation/agents"
+	"drone_simulation/store"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testStart     = time.Date(2011, 3, 22, 7, 47, 55, 0, time.UTC)
+	testLocation  = store.Location{DroneID: 5937, Latitude: 51.4965, Longitude: -0.1449, Time: testStart}
+	testLocation2 = store.Location{DroneID: 5937, Latitude: 51.4974, Longitude: -0.1449, Time: testStart.Add(10 * time.Second)}
+	testReport    = store.TrafficReport{DroneID: 5937, Station: "Victoria", Latitude: 51.4965, Longitude: -0.1447, Time: testStart, Condition: store.TrafficHeavy}
+	testEvents    = []agents.Event{
+		{Seq: 1, Kind: agents.EventLaunch, DroneID: 5937, Time: testStart},
+		{Seq: 2, Kind: agents.EventMove, DroneID: 5937, Time: testStart, Location: &testLocation, Battery: 1},
+		{Seq: 3, Kind: agents.EventReport, DroneID: 5937, Time: testStart, Report: &testReport},
+		{Seq: 4, Kind: agents.EventMove, DroneID: 5937, Time: testLocation2.Time, Location: &testLocation2, Battery: 0.95},
+		{Seq: 5, Kind: agents.EventLaunch, DroneID: 6043, Time: testStart},
+		{Seq: 6, Kind: agents.EventShutDown, DroneID: 6043, Time: testStart, Reason: "empty route"},
+	}
+)
+
+func TestView_Handle(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a view
+	view := NewView(ViewConfig{})
+
+	// When it handles the flight of a drone and the shutdown of another
+	for _, event := range testEvents {
+		view.Handle(event)
+	}
+
+	// Then it should hold a row per drone
+	drones := view.Drones()
+	assert.Len(drones, 2)
+	assert.Equal(5937, drones[0].ID)
+	assert.Equal("on", drones[0].Status)
+	assert.Equal(&testLocation2, drones[0].Location)
+	assert.InDelta(36, drones[0].SpeedKph, 0.1, "a hundred metres in ten seconds")
+	assert.Equal(0.95, drones[0].Battery)
+	assert.Equal(1, drones[0].Memory)
+	assert.Equal("Victoria", drones[0].LastStation)
+	assert.Equal(DroneRow{ID: 6043, Status: "off", Reason: "empty route"}, drones[1])
+
+	// When the drone restarts
+	view.Handle(agents.Event{Seq: 7, Kind: agents.EventRestart, DroneID: 5937, Time: testLocation2.Time})
+
+	// Then its memory should be empty again
+	assert.Equal(0, view.Drones()[0].Memory)
+	assert.Equal("Victoria", view.Drones()[0].LastStation)
+}
+
+func TestView_Feed(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a view keeping two reports
+	view := NewView(ViewConfig{FeedSize: 2})
+
+	// When it handles three reports and a fusion
+	for i, station := range []string{"Victoria", "Pimlico", "Vauxhall"} {
+		report := testReport
+		report.Station = station
+		view.Handle(agents.Event{Seq: i + 1, Kind: agents.EventReport, DroneID: 5937, Time: testStart, Report: &report})
+	}
+	fused := testReport
+	view.Handle(agents.Event{Seq: 4, Kind: agents.EventFusion, Time: testStart, Report: &fused, Fusion: &agents.Fusion{Strategy: "latest"}})
+
+	// Then it should keep the latest, newest first, without a row for the fusion
+	assert.Len(view.feed, 2)
+	assert.Equal(agents.EventFusion, view.feed[0].Kind)
+	assert.Equal("Vauxhall", view.feed[1].Report.Station)
+	assert.Len(view.Drones(), 1)
+}
+
+func TestView_Frame(t *testing.T) {
+	status := clockStatus{Paused: true, Speed: 4}
+
+	testCases := []struct {
+		name          string
+		width         int
+		height        int
+		expectedLines []string
+	}{
+		{
+			name:   "frame() should show the clock, a row per drone and the feed",
+			width:  100,
+			height: 24,
+			expectedLines: []string{
+				"Drone simulation  2011-03-22 07:48:05  paused at 4x",
+				help,
+				"",
+				bold + "DRONE  STATE                POSITION                 SPEED BATTERY MEMORY  LAST STATION" + reset,
+				"5937   on                   51.49740, -0.14490   36.0 km/h     95%   1/10  Victoria",
+				"6043   off (empty route)                                             0/10  ",
+				"",
+				bold + "TRAFFIC REPORTS" + reset,
+				"07:47:55  5937   Victoria                 " + conditionColors[store.TrafficHeavy] + "HEAVY" + reset,
+			},
+		},
+		{
+			name:   "frame() should cut the lines and the feed to the terminal",
+			width:  20,
+			height: 5,
+			expectedLines: []string{
+				"Drone simulation  20",
+				"p pause/resume  s st",
+				"",
+				bold + "DRONE  STATE        " + reset,
+				"5937   on           ",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given a view of a drone that reported traffic and one that shut down
+			view := NewView(ViewConfig{})
+			for _, event := range testEvents {
+				view.Handle(event)
+			}
+
+			// When
+			lines := view.frame(status, testCase.width, testCase.height)
+
+			// Then
+			assert.Equal(t, testCase.expectedLines, lines)
+		})
+	}
+}
+
+func TestPress(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a dispatcher running at real time
+	dispatcher := agents.NewDispatcherWithConfig(agents.DispatcherConfig{Clock: agents.NewClock(agents.ClockConfig{Start: testStart})})
+
+	// When p is pressed, twice
+	assert.True(press('p', dispatcher))
+	// Then it should pause, then resume
+	assert.True(dispatcher.Paused())
+	assert.True(press('p', dispatcher))
+	assert.False(dispatcher.Paused())
+
+	// When + is pressed twice and - once
+	press('+', dispatcher)
+	press('+', dispatcher)
+	press('-', dispatcher)
+	// Then it should run twice as fast
+	assert.Equal(2.0, dispatcher.Speed())
+
+	// When s is pressed
+	press('s', dispatcher)
+	// Then it should be paused at its current time
+	assert.True(dispatcher.Paused())
+
+	// When q or Ctrl-C is pressed while a drone waits, paused, for its next waypoint an hour away
+	drone := agents.NewDrone(5937, agents.DroneConfig{StationRepo: &store.MockStationRepository{}})
+	routeRepo := &store.MockRouteRepository{GetRouteFunc: func(id int) ([]store.Location, error) {
+		next := testLocation2
+		next.Time = testStart.Add(time.Hour)
+		return []store.Location{testLocation, next}, nil
+	}}
+	assert.NoError(dispatcher.AddDrone(drone, routeRepo, time.Time{}))
+	assert.Eventually(func() bool { return dispatcher.ListDrones()[0].Location == testLocation }, 5*time.Second, time.Millisecond)
+	// Then the view should quit, and the drone land without the simulation resuming
+	assert.False(press('q', dispatcher))
+	assert.False(press(ctrlC, dispatcher))
+	dispatcher.Wait()
+	assert.False(drone.IsOn())
+	assert.True(dispatcher.Paused())
+}
+
+func TestView_RunNotTerminal(t *testing.T) {
+	// Given a view reading its keys from a file
+	file, err := os.Create(filepath.Join(t.TempDir(), "keys"))
+	assert.NoError(t, err)
+	defer file.Close()
+	view := NewView(ViewConfig{Input: file})
+
+	// When it is run
+	err = view.Run(agents.NewDispatcher(nil), make(chan struct{}))
+
+	// Then it should not take over the terminal
+	assert.ErrorIs(t, err, ErrNotTerminal)
+}
+
+func TestReadKeys_Stopped(t *testing.T) {
+	// Given keys read from a pipe that nothing receives from any more
+	r, w := io.Pipe()
+	defer w.Close()
+	keys, stopped := make(chan byte), make(chan struct{})
+	returned := make(chan struct{})
+	go func() {
+		readKeys(r, keys, stopped)
+		close(returned)
+	}()
+
+	// When the view stops and a key is typed
+	close(stopped)
+	go w.Write([]byte("p"))
+
+	// Then the keys should no longer be read
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("readKeys did not return once stopped")
+	}
+	_, ok := <-keys
+	assert.False(t, ok)
+}
+
+func TestColorCondition(t *testing.T) {
+	testCases := []struct {
+		name      string
+		line      string
+		condition string
+		expected  string
+	}{
+		{name: "colorCondition() should color the condition ending a line", line: "07:47:55 Victoria LIGHT", condition: store.TrafficLight, expected: "07:47:55 Victoria " + conditionColors[store.TrafficLight] + "LIGHT" + reset},
+		{name: "colorCondition() should not color a cut condition", line: "07:47:55 Victoria LIG", condition: store.TrafficLight, expected: "07:47:55 Victoria LIG"},
+		{name: "colorCondition() should not color an unknown condition", line: "07:47:55 Victoria FOG", condition: "FOG", expected: "07:47:55 Victoria FOG"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, colorCondition(testCase.line, testCase.condition))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"drone_simulation/store"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// validate reports the lines that cannot be parsed and what cleaning would change in the routes of the given drones,
+// and fails if there are any
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: simulation validate [drone ID...]")
+		flags.PrintDefaults()
+	}
+	formats := addFormatFlags(flags)
+	flags.Parse(args)
+
+	format, err := formats.parse()
+	if err != nil {
+		logrus.Error(err)
+		return 2
+	}
+
+	ids, err := parseDroneIDs(flags.Args(), drones)
+	if err != nil {
+		logrus.Error(err)
+		return 2
+	}
+
+	status := 0
+	cleaner := store.DefaultRouteCleaner()
+	routeRepo := format.routes(store.Lenient)
+	for _, id := range ids {
+		route, err := routeRepo.ParseRoute(id)
+		var diagnostics store.ParseErrors
+		if errors.As(err, &diagnostics) {
+			for _, diagnostic := range diagnostics {
+				fmt.Println(diagnostic)
+			}
+			status = 1
+		} else if err != nil {
+			logrus.WithField("Drone", id).Errorf("Could not read route: %s", err)
+			status = 1
+			continue
+		}
+
+		_, report := cleaner.Clean(route)
+		fmt.Printf("drone %d: %s\n", id, report)
+		for _, removed := range report.Removed {
+			fmt.Printf("  removed %s (%f, %f): %s: %s\n", removed.Location.Time.Format(time.TimeOnly),
+				removed.Location.Latitude, removed.Location.Longitude, removed.Step, removed.Reason)
+		}
+		for _, gap := range report.Gaps {
+			fmt.Printf("  gap of %s from %s to %s\n", gap.To.Time.Sub(gap.From.Time),
+				gap.From.Time.Format(time.TimeOnly), gap.To.Time.Format(time.TimeOnly))
+		}
+
+		if report.Changed() {
+			status = 1
+		}
+	}
+	return status
+}
+
+// parseDroneIDs parses the drone IDs given as arguments, or returns the default ones if none is given
+func parseDroneIDs(args []string, defaults []int) ([]int, error) {
+	if len(args) == 0 {
+		return defaults, nil
+	}
+
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid drone ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}